@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+const (
+	accountActive       = "active"
+	accountSuspended    = "suspended"
+	accountBanned       = "banned"
+	accountShadowbanned = "shadowbanned"
+)
+
+var (
+	errAccountSuspended = errors.New("account is suspended")
+	errAccountBanned    = errors.New("account is banned")
+)
+
+// checkAccountStatus reports whether u may sign in and use the API. A
+// suspension that has run out counts as active. Shadowbanned accounts are
+// let through so they don't notice the ban.
+func checkAccountStatus(u database.User, now time.Time) error {
+	switch u.Status {
+	case accountBanned:
+		return errAccountBanned
+	case accountSuspended:
+		if u.SuspendedUntil.Valid && now.Before(u.SuspendedUntil.Time) {
+			return errAccountSuspended
+		}
+	}
+	return nil
+}
+
+func respondWithAccountStatusError(w http.ResponseWriter, u database.User, err error) {
+	if errors.Is(err, errAccountSuspended) {
+		msg := fmt.Sprintf("Account suspended until %s", u.SuspendedUntil.Time.UTC().Format(time.RFC3339))
+		respondWithError(w, http.StatusForbidden, msg, err)
+		return
+	}
+	respondWithError(w, http.StatusForbidden, "Account banned", err)
+}
+
+// middlewareAccountStatus turns away requests whose JWT belongs to a
+// suspended or banned account, so an access token stops working as soon as
+// the account is actioned. Requests without a valid JWT pass through and
+// are left to each handler's own checks.
+func (cfg *apiConfig) middlewareAccountStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := auth.ValidateJWT(token, cfg.keys)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u, err := cfg.db.GetUserFromID(r.Context(), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking account", err)
+			return
+		}
+
+		err = checkAccountStatus(u, time.Now())
+		if err != nil {
+			respondWithAccountStatusError(w, u, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// viewerID returns the caller's user ID when the request carries a valid
+// JWT, for endpoints that also serve anonymous callers.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.keys)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// chirpVisibleTo reports whether viewer may see chirp. Chirps by
+// shadowbanned authors are only visible to the author.
+func (cfg *apiConfig) chirpVisibleTo(ctx context.Context, chirp database.Chirp, viewer uuid.NullUUID) (bool, error) {
+	if viewer.Valid && viewer.UUID == chirp.UserID {
+		return true, nil
+	}
+
+	author, err := cfg.db.GetUserFromID(ctx, chirp.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	return author.Status != accountShadowbanned, nil
+}
+
+func (cfg *apiConfig) setAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Status         string     `json:"status"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	params := database.SetUserStatusParams{
+		ID:     userID,
+		Status: requestBody.Status,
+	}
+	switch requestBody.Status {
+	case accountActive, accountBanned, accountShadowbanned:
+		if requestBody.SuspendedUntil != nil {
+			respondWithError(w, http.StatusBadRequest, "suspended_until is only valid when suspending", errors.New("unexpected suspended_until"))
+			return
+		}
+	case accountSuspended:
+		if requestBody.SuspendedUntil == nil || !requestBody.SuspendedUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "Suspension needs a future suspended_until", errors.New("invalid suspended_until"))
+			return
+		}
+		params.SuspendedUntil = sql.NullTime{Time: requestBody.SuspendedUntil.UTC(), Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status", fmt.Errorf("unknown account status %q", requestBody.Status))
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating account", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	u, err := qtx.SetUserStatus(r.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating account", err)
+		return
+	}
+
+	// Suspended and banned accounts lose every session at once; access
+	// tokens are refused by middlewareAccountStatus until they expire.
+	if u.Status == accountSuspended || u.Status == accountBanned {
+		err = qtx.RevokeAllSessions(r.Context(), u.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating account", err)
+		return
+	}
+
+	status := AccountStatus{
+		UserID: u.ID,
+		Status: u.Status,
+	}
+	if u.SuspendedUntil.Valid {
+		status.SuspendedUntil = &u.SuspendedUntil.Time
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+func (cfg *apiConfig) setRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Role string `json:"role"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	role, err := auth.ParseRole(requestBody.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid role", err)
+		return
+	}
+
+	u, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: string(role),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating role", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, UserRole{
+		UserID: u.ID,
+		Role:   u.Role,
+	})
+}
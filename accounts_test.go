@@ -0,0 +1,48 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/database"
+)
+
+func TestCheckAccountStatus(t *testing.T) {
+	now := time.Date(2025, time.April, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		user    database.User
+		wantErr error
+	}{
+		{name: "Active", user: database.User{Status: accountActive}},
+		{name: "Shadowbanned", user: database.User{Status: accountShadowbanned}},
+		{name: "Banned", user: database.User{Status: accountBanned}, wantErr: errAccountBanned},
+		{
+			name: "Suspended",
+			user: database.User{
+				Status:         accountSuspended,
+				SuspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+			},
+			wantErr: errAccountSuspended,
+		},
+		{
+			name: "Suspension over",
+			user: database.User{
+				Status:         accountSuspended,
+				SuspendedUntil: sql.NullTime{Time: now.Add(-time.Second), Valid: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAccountStatus(tt.user, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkAccountStatus() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/emailaddr"
+	"github.com/migomi3/internal/pwpolicy"
+)
+
+// runCommand handles the maintenance subcommands run as
+// `chirpy <command> [flags]` instead of starting the server.
+func runCommand(ctx context.Context, db *sql.DB, args []string) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdminCommand(ctx, db, args[1:])
+	case "build-breach-index":
+		return buildBreachIndexCommand(args[1:])
+	case "generate-signing-key":
+		return generateSigningKeyCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// createAdminCommand bootstraps an admin account. An existing user is
+// promoted; otherwise the user is created with the password from
+// CHIRPY_ADMIN_PASSWORD, or read from stdin when that is unset.
+func createAdminCommand(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email address of the admin account")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("create-admin: -email is required")
+	}
+	address, err := emailaddr.Normalize(*email)
+	if err != nil {
+		return fmt.Errorf("create-admin: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := database.New(tx)
+
+	u, err := qtx.GetUser(ctx, address)
+	if errors.Is(err, sql.ErrNoRows) {
+		password, err := readAdminPassword()
+		if err != nil {
+			return err
+		}
+
+		policy, err := passwordPolicyFromEnv()
+		if err != nil {
+			return err
+		}
+		violations, err := policy.Check(password, address)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return fmt.Errorf("create-admin: %s", violations[0].Message)
+		}
+
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		u, err = qtx.CreateUser(ctx, database.CreateUserParams{
+			Email:          address,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, err = qtx.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   u.ID,
+		Role: string(auth.RoleAdmin),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The operator vouches for the address, so there is no link to follow.
+	_, err = qtx.MarkEmailVerified(ctx, database.MarkEmailVerifiedParams{ID: u.ID, Email: u.Email})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) is now an admin\n", u.Email, u.ID)
+	return nil
+}
+
+func readAdminPassword() (string, error) {
+	if password := os.Getenv("CHIRPY_ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return password, nil
+}
+
+// buildBreachIndexCommand turns a breached-password corpus, one password or
+// SHA-1 hash per line, into the index read via BREACHED_PASSWORDS_INDEX.
+func buildBreachIndexCommand(args []string) error {
+	flags := flag.NewFlagSet("build-breach-index", flag.ContinueOnError)
+	in := flags.String("in", "", "corpus file, one password or SHA-1 hash per line")
+	out := flags.String("out", "", "index file to write")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		return errors.New("build-breach-index: -in and -out are required")
+	}
+
+	corpus, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer corpus.Close()
+
+	hashes, err := pwpolicy.ReadCorpus(corpus)
+	if err != nil {
+		return err
+	}
+
+	// Write beside the target and rename, so a running server never opens
+	// a half-written index.
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".breach-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = pwpolicy.WriteIndex(tmp, hashes)
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), *out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Indexed %d entries into %s\n", len(hashes), *out)
+	return nil
+}
+
+// generateSigningKeyCommand writes a new private key for JWT_SIGNING_KEY.
+// To rotate, point JWT_SIGNING_KEY at the new file and add the old one to
+// JWT_RETIRED_KEYS until the tokens it signed have expired.
+func generateSigningKeyCommand(args []string) error {
+	flags := flag.NewFlagSet("generate-signing-key", flag.ContinueOnError)
+	alg := flags.String("alg", auth.AlgEdDSA, "signing algorithm, EdDSA or RS256")
+	out := flags.String("out", "", "private key file to write")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("generate-signing-key: -out is required")
+	}
+
+	key, err := auth.GenerateKey(*alg)
+	if err != nil {
+		return fmt.Errorf("generate-signing-key: %w", err)
+	}
+
+	private, err := key.PrivatePEM()
+	if err != nil {
+		return err
+	}
+	public, err := key.PublicPEM()
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL so an existing key, possibly still in use, is never replaced.
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(private)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s key %s to %s\n%s", key.Alg, key.ID, *out, public)
+	return nil
+}
@@ -6,7 +6,12 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/rivo/uniseg v0.4.7
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.32.0
+	golang.org/x/text v0.21.0
 )
 
 require github.com/golang-jwt/jwt/v5 v5.2.1
+
+require golang.org/x/sys v0.29.0 // indirect
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/charcount"
 	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/emailaddr"
+	"github.com/migomi3/internal/entities"
 )
 
 func (cfg *apiConfig) healthEndpointHandler(w http.ResponseWriter, r *http.Request) {
@@ -55,7 +61,8 @@ func (cfg *apiConfig) chirpsHandler(w http.ResponseWriter, r *http.Request) {
 
 	decoder := json.NewDecoder(r.Body)
 	requestBody := struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
 	}{}
 	err = decoder.Decode(&requestBody)
 	if err != nil {
@@ -63,31 +70,163 @@ func (cfg *apiConfig) chirpsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := auth.ValidateJWT(token, cfg.secret)
+	id, err := auth.ValidateJWT(token, cfg.keys)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
 		return
 	}
 
+	err = cfg.requireVerifiedEmail(r.Context(), id)
+	if err != nil {
+		respondWithEmailVerificationError(w, err)
+		return
+	}
+
 	params := database.CreateChirpParams{
 		Body:   requestBody.Body,
 		UserID: id,
 	}
+	if requestBody.InReplyTo != nil {
+		params.InReplyTo = uuid.NullUUID{UUID: *requestBody.InReplyTo, Valid: true}
+	}
+
+	limits, err := cfg.entitlementsFor(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking entitlements", err)
+		return
+	}
+
+	moderated, err := prepareChirpBody(params.Body, limits.MaxChirpLength, cfg.wordList)
+	if err != nil {
+		respondWithChirpBodyError(w, err)
+		return
+	}
+	params.Body = moderated.Body
+
+	// Replying to a chirp the caller can't see would reveal that it exists.
+	if params.InReplyTo.Valid {
+		parent, err := cfg.db.GetChirp(r.Context(), params.InReplyTo.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Parent chirp not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+			return
+		}
+
+		visible, err := cfg.chirpVisibleTo(r.Context(), parent, uuid.NullUUID{UUID: id, Valid: true})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+			return
+		}
+		if !visible {
+			respondWithError(w, http.StatusNotFound, "Parent chirp not found", errors.New("author is shadowbanned"))
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if params.InReplyTo.Valid {
+		n, err := qtx.IncrementReplyCount(r.Context(), params.InReplyTo.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+			return
+		}
+		if n == 0 {
+			respondWithError(w, http.StatusNotFound, "Parent chirp not found", errors.New("reply to missing or deleted chirp"))
+			return
+		}
+	}
+
+	chirpEntities, err := resolveEntities(r.Context(), qtx, params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving mentions", err)
+		return
+	}
+
+	params.Entities, err = json.Marshal(chirpEntities)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
 
-	if len(params.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Message exceeds character limit", err)
+	chirp, err := qtx.CreateChirp(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	err = indexChirp(r.Context(), qtx, chirp, chirpEntities)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
 		return
 	}
 
-	params.Body = cleanMessage(params.Body)
+	err = flagChirp(r.Context(), qtx, chirp, moderated)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), params)
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, chirp)
+	respondWithJSON(w, http.StatusCreated, chirpFromDB(chirp))
+}
+
+func (cfg *apiConfig) validateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	id, err := auth.ValidateJWT(token, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Body string `json:"body"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	limits, err := cfg.entitlementsFor(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking entitlements", err)
+		return
+	}
+
+	moderated, err := prepareChirpBody(requestBody.Body, limits.MaxChirpLength, cfg.wordList)
+	length := charcount.Count(moderated.Body)
+	validation := ChirpValidation{
+		Valid:     err == nil,
+		Length:    length,
+		MaxLength: limits.MaxChirpLength,
+		Remaining: limits.MaxChirpLength - length,
+	}
+	if err != nil {
+		validation.Error = err.Error()
+	}
+
+	respondWithJSON(w, http.StatusOK, validation)
 }
 
 func (cfg *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
@@ -99,6 +238,24 @@ func (cfg *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var fields []FieldError
+	email, err := emailaddr.Normalize(loginParams.Email)
+	if err != nil {
+		fields = append(fields, invalidEmailField)
+		email = loginParams.Email
+	}
+
+	passwordFields, err := passwordFieldErrors(cfg.passwordPolicy, loginParams.Password, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+		return
+	}
+	fields = append(fields, passwordFields...)
+	if len(fields) > 0 {
+		respondWithValidationErrors(w, fields)
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(loginParams.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Password Hashing failed", err)
@@ -106,7 +263,7 @@ func (cfg *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	params := database.CreateUserParams{
-		Email:          loginParams.Email,
+		Email:          email,
 		HashedPassword: hashedPassword,
 	}
 
@@ -116,48 +273,82 @@ func (cfg *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := User{
-		ID:          u.ID,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Email:       u.Email,
-		IsChirpyRed: u.IsChirpyRed,
+	err = cfg.sendEmailVerification(r.Context(), u.ID, u.Email)
+	if err != nil {
+		log.Printf("Error sending verification email to user %s: %s", u.ID, err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, user)
+	respondWithJSON(w, http.StatusCreated, userFromDB(u, false))
 }
 
 func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	var chirps []database.Chirp
-	var err error
+	desc := strings.ToLower(r.URL.Query().Get("sort")) == "desc"
+	pageSize, cursor, err := parsePageParams(r, desc)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
 
-	authorID := r.URL.Query().Get("author_id")
-	if authorID != "" {
-		id, err := uuid.Parse(authorID)
+	authorID := uuid.NullUUID{}
+	if a := r.URL.Query().Get("author_id"); a != "" {
+		id, err := uuid.Parse(a)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid id", err)
 			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
 
-		chirps, err = cfg.db.GetChirpsFromUser(r.Context(), id)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
-			return
-		}
-	} else {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
-			return
-		}
+	// Fetch one extra row so we know whether another page follows.
+	chirps, err := cfg.listChirps(r.Context(), authorID, cfg.viewerID(r), cursor, desc, pageSize+1)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
+		return
 	}
 
-	sortBy := r.URL.Query().Get("sort")
-	if strings.ToLower(sortBy) == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
+	page := newChirpsPage(chirps, pageSize)
+	err = cfg.addViewerFlags(r, page.Chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, chirps)
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func (cfg *apiConfig) listChirps(ctx context.Context, authorID, viewerID uuid.NullUUID, cursor pageCursor, desc bool, pageSize int32) ([]database.Chirp, error) {
+	switch {
+	case authorID.Valid && desc:
+		return cfg.db.ListChirpsFromUserDesc(ctx, database.ListChirpsFromUserDescParams{
+			UserID:          authorID.UUID,
+			BeforeCreatedAt: cursor.CreatedAt,
+			BeforeID:        cursor.ID,
+			ViewerID:        viewerID,
+			PageSize:        pageSize,
+		})
+	case authorID.Valid:
+		return cfg.db.ListChirpsFromUserAsc(ctx, database.ListChirpsFromUserAscParams{
+			UserID:         authorID.UUID,
+			AfterCreatedAt: cursor.CreatedAt,
+			AfterID:        cursor.ID,
+			ViewerID:       viewerID,
+			PageSize:       pageSize,
+		})
+	case desc:
+		return cfg.db.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+			BeforeCreatedAt: cursor.CreatedAt,
+			BeforeID:        cursor.ID,
+			ViewerID:        viewerID,
+			PageSize:        pageSize,
+		})
+	default:
+		return cfg.db.ListChirpsAsc(ctx, database.ListChirpsAscParams{
+			AfterCreatedAt: cursor.CreatedAt,
+			AfterID:        cursor.ID,
+			ViewerID:       viewerID,
+			PageSize:       pageSize,
+		})
+	}
 }
 
 func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
@@ -168,12 +359,84 @@ func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	chirp, err := cfg.db.GetChirp(r.Context(), id)
-	if err != nil {
+	if err != nil || chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, chirp)
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, cfg.viewerID(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirp", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", errors.New("author is shadowbanned"))
+		return
+	}
+
+	resp := []Chirp{chirpFromDB(chirp)}
+	err = cfg.addViewerFlags(r, resp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
+
+func (cfg *apiConfig) exportChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	// The export is paged like any other listing, oldest first, so a long
+	// history is never loaded in one go.
+	pageSize, cursor, err := parsePageParams(r, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	limits, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking entitlements", err)
+		return
+	}
+
+	since := time.Time{}
+	if limits.ExportHistoryDays > 0 {
+		since = time.Now().AddDate(0, 0, -limits.ExportHistoryDays)
+	}
+
+	rows, err := cfg.db.ListChirpsForExport(r.Context(), database.ListChirpsForExportParams{
+		UserID:         userID,
+		Since:          since,
+		AfterCreatedAt: cursor.CreatedAt,
+		AfterID:        cursor.ID,
+		PageSize:       pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newChirpsPage(rows, pageSize))
+}
+
+// jwksHandler publishes the public half of every key in the keyring so
+// other services can verify Chirpy's tokens without sharing a secret.
+// Caches must expire well before a retired key is dropped.
+func (cfg *apiConfig) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.keys.JWKS())
 }
 
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -185,19 +448,71 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u, err := cfg.db.GetUser(r.Context(), loginParams.Email)
+	// Unknown and malformed emails get the same answer, and the same delay,
+	// as a wrong password, so login can't be used to find accounts.
+	email, err := emailaddr.Normalize(loginParams.Email)
+	if err != nil {
+		email = strings.ToLower(strings.TrimSpace(loginParams.Email))
+	}
+	attempt := newLoginAttempt(r, email)
+
+	wait, err := cfg.allowLogin(r.Context(), attempt)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "user not found", err)
+		cfg.recordAuthEvent(r, authEventLoginThrottled, uuid.NullUUID{}, email)
+		respondWithLoginThrottleError(w, wait, err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(loginParams.Password, u.HashedPassword)
+	u, err := cfg.db.GetUser(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in", err)
+		return
+	}
 	if err != nil {
+		auth.CheckPasswordHash(loginParams.Password, dummyPasswordHash())
+		cfg.failLogin(r.Context(), attempt)
+		cfg.recordAuthEvent(r, authEventLoginFailed, uuid.NullUUID{}, email)
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
-	JWTTokenString, err := auth.MakeJWT(u.ID, cfg.secret, time.Hour)
+	needsRehash, err := auth.CheckPasswordHash(loginParams.Password, u.HashedPassword)
+	if err != nil {
+		cfg.failLogin(r.Context(), attempt)
+		cfg.recordAuthEvent(r, authEventLoginFailed, uuid.NullUUID{UUID: u.ID, Valid: true}, email)
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		return
+	}
+	cfg.succeedLogin(r.Context(), attempt)
+	if needsRehash {
+		cfg.rehashPassword(r.Context(), u, loginParams.Password)
+	}
+
+	err = checkAccountStatus(u, time.Now())
+	if err != nil {
+		respondWithAccountStatusError(w, u, err)
+		return
+	}
+
+	enabled, err := cfg.twoFactorEnabled(r.Context(), u.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking two-factor authentication", err)
+		return
+	}
+	if enabled {
+		cfg.recordAuthEvent(r, authEventTwoFactorRequired, uuid.NullUUID{UUID: u.ID, Valid: true}, email)
+		cfg.respondWithTwoFactorChallenge(w, u)
+		return
+	}
+
+	cfg.recordAuthEvent(r, authEventLoginSucceeded, uuid.NullUUID{UUID: u.ID, Valid: true}, email)
+	cfg.startSession(w, r, u)
+}
+
+// startSession issues the access and refresh tokens once every login factor
+// has been checked.
+func (cfg *apiConfig) startSession(w http.ResponseWriter, r *http.Request, u database.User) {
+	JWTTokenString, err := auth.MakeJWT(u.ID, auth.Role(u.Role), cfg.keys, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error Creating JWT", err)
 		return
@@ -212,7 +527,10 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	refreshTokenParams := database.CreateRefreshTokenParams{
 		Token:     refreshString,
 		UserID:    u.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 1440),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		FamilyID:  uuid.New(),
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
 	}
 
 	_, err = cfg.db.CreateRefreshToken(r.Context(), refreshTokenParams)
@@ -221,19 +539,40 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := User{
-		ID:           u.ID,
-		CreatedAt:    u.CreatedAt,
-		UpdatedAt:    u.UpdatedAt,
-		Email:        u.Email,
-		Token:        JWTTokenString,
-		RefreshToken: refreshString,
-		IsChirpyRed:  u.IsChirpyRed,
+	isChirpyRed, err := cfg.db.IsChirpyRed(r.Context(), u.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking subscription", err)
+		return
 	}
 
+	user := userFromDB(u, isChirpyRed)
+	user.Token = JWTTokenString
+	user.RefreshToken = refreshString
+
 	respondWithJSON(w, http.StatusOK, user)
 }
 
+// rehashPassword upgrades a hash made with an older algorithm or weaker
+// parameters while the plaintext is at hand. The swap only applies if the
+// hash is unchanged, so it can't undo a password change made meanwhile. A
+// failure just leaves the old hash for next time.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, u database.User, password string) {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Error rehashing password for user %s: %s", u.ID, err)
+		return
+	}
+
+	_, err = cfg.db.RehashPassword(ctx, database.RehashPasswordParams{
+		NewHash: hash,
+		ID:      u.ID,
+		OldHash: u.HashedPassword,
+	})
+	if err != nil {
+		log.Printf("Error rehashing password for user %s: %s", u.ID, err)
+	}
+}
+
 func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 	refreshTokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -247,31 +586,107 @@ func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if refreshToken.RevokedAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Token revoked", err)
+		return
+	}
+
+	// A token that was already exchanged should never be presented again;
+	// if it is, assume it was stolen and end the whole session.
+	if refreshToken.RotatedAt.Valid {
+		cfg.revokeTokenFamily(w, r, refreshToken.FamilyID)
+		return
+	}
+
 	if refreshToken.ExpiresAt.Before(time.Now()) {
 		respondWithError(w, http.StatusUnauthorized, "Token expired", err)
 		return
 	}
 
-	if !refreshToken.RevokedAt.Time.Equal(sql.NullTime{}.Time) {
-		respondWithError(w, http.StatusUnauthorized, "Token revoked", err)
+	u, err := cfg.db.GetUserFromID(r.Context(), refreshToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User not found", err)
+		return
+	}
+
+	err = checkAccountStatus(u, time.Now())
+	if err != nil {
+		respondWithAccountStatusError(w, u, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating refresh token", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	_, err = qtx.RotateRefreshToken(r.Context(), refreshToken.Token)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Lost a race with another request presenting the same token.
+		tx.Rollback()
+		cfg.revokeTokenFamily(w, r, refreshToken.FamilyID)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating refresh token", err)
+		return
+	}
+
+	refreshString, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error Creating Refresh Token", err)
 		return
 	}
 
-	JWTTokenString, err := auth.MakeJWT(refreshToken.UserID, cfg.secret, time.Hour)
+	_, err = qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:     refreshString,
+		UserID:    refreshToken.UserID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		FamilyID:  refreshToken.FamilyID,
+		UserAgent: refreshToken.UserAgent,
+		IpAddress: refreshToken.IpAddress,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Refresh token could not be created", err)
+		return
+	}
+
+	JWTTokenString, err := auth.MakeJWT(u.ID, auth.Role(u.Role), cfg.keys, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error Creating JWT", err)
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rotating refresh token", err)
+		return
+	}
+
 	resp := struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
-		Token: JWTTokenString,
+		Token:        JWTTokenString,
+		RefreshToken: refreshString,
 	}
 
 	respondWithJSON(w, http.StatusOK, resp)
 }
 
+func (cfg *apiConfig) revokeTokenFamily(w http.ResponseWriter, r *http.Request, familyID uuid.UUID) {
+	err := cfg.db.RevokeTokenFamily(r.Context(), familyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke token family", err)
+		return
+	}
+
+	respondWithError(w, http.StatusUnauthorized, "Token reuse detected", fmt.Errorf("refresh token family %s revoked after reuse", familyID))
+}
+
 func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 	refreshTokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -279,7 +694,15 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.db.RevokeToken(r.Context(), refreshTokenString)
+	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), refreshTokenString)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Token not found", err)
+		return
+	}
+
+	// Logging out ends the session, not just the one token: after a
+	// rotation the earlier tokens of the family must not outlive it.
+	err = cfg.db.RevokeTokenFamily(r.Context(), refreshToken.FamilyID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to revoke token", err)
 		return
@@ -288,6 +711,9 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// updateUserHandler changes the password straight away. A new email is only
+// recorded as pending; it replaces the current one once the link sent to it
+// is followed.
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	JWTTokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -295,9 +721,10 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	id, err := auth.ValidateJWT(JWTTokenString, cfg.secret)
+	id, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -308,30 +735,126 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(loginParams.Password)
+	u, err := cfg.db.GetUserFromID(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Password Hashing failed", err)
+		respondWithError(w, http.StatusInternalServerError, "User not found", err)
 		return
 	}
 
-	params := database.UpdateLoginInfoParams{
-		Email:          loginParams.Email,
-		HashedPassword: hashedPassword,
-		ID:             id,
+	email := u.Email
+	if loginParams.Email != "" {
+		email, err = emailaddr.Normalize(loginParams.Email)
+		if err != nil {
+			respondWithValidationErrors(w, []FieldError{invalidEmailField})
+			return
+		}
 	}
-	u, err := cfg.db.UpdateLoginInfo(r.Context(), params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "User not found", err)
+
+	// The handle is checked before anything is written, so a taken or
+	// invalid handle doesn't leave the rest of the update half applied.
+	if loginParams.Handle != "" && loginParams.Handle != u.Handle.String {
+		if !entities.ValidHandle(loginParams.Handle) {
+			respondWithValidationErrors(w, []FieldError{invalidHandleField})
+			return
+		}
+
+		owner, err := cfg.db.GetUserByHandle(r.Context(), loginParams.Handle)
+		if err == nil && owner.ID != u.ID {
+			respondWithError(w, http.StatusConflict, "Handle already taken", nil)
+			return
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Error updating handle", err)
+			return
+		}
+	}
+
+	if loginParams.Password != "" {
+		fields, err := passwordFieldErrors(cfg.passwordPolicy, loginParams.Password, email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+			return
+		}
+		if len(fields) > 0 {
+			respondWithValidationErrors(w, fields)
+			return
+		}
+
+		hashedPassword, err := auth.HashPassword(loginParams.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Password Hashing failed", err)
+			return
+		}
+
+		u, err = cfg.db.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+			ID:             u.ID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "User not found", err)
+			return
+		}
+	}
+
+	if loginParams.Handle != "" && loginParams.Handle != u.Handle.String {
+		u, err = cfg.db.SetUserHandle(r.Context(), database.SetUserHandleParams{
+			ID:     u.ID,
+			Handle: sql.NullString{String: loginParams.Handle, Valid: true},
+		})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			respondWithError(w, http.StatusConflict, "Handle already taken", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating handle", err)
+			return
+		}
+	}
+
+	if email != u.Email && email != u.PendingEmail.String {
+		_, err = cfg.db.GetUser(r.Context(), email)
+		if err == nil {
+			respondWithError(w, http.StatusConflict, "Email already in use", nil)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Error updating email", err)
+			return
+		}
+
+		u, err = cfg.db.SetPendingEmail(r.Context(), database.SetPendingEmailParams{
+			ID:           u.ID,
+			PendingEmail: sql.NullString{String: email, Valid: true},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating email", err)
+			return
+		}
+
+		err = cfg.sendEmailVerification(r.Context(), u.ID, email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error sending verification email", err)
+			return
+		}
+		cfg.notifyEmailChange(r.Context(), u.Email, email, false)
+	} else if email == u.Email && u.PendingEmail.Valid {
+		// Asking for the current address again cancels a pending change.
+		u, err = cfg.db.SetPendingEmail(r.Context(), database.SetPendingEmailParams{ID: u.ID})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating email", err)
+			return
+		}
 	}
 
-	user := User{
-		ID:          u.ID,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Email:       u.Email,
-		Token:       JWTTokenString,
-		IsChirpyRed: u.IsChirpyRed,
+	isChirpyRed, err := cfg.db.IsChirpyRed(r.Context(), u.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking subscription", err)
+		return
 	}
+
+	user := userFromDB(u, isChirpyRed)
+	user.Token = JWTTokenString
 	respondWithJSON(w, http.StatusOK, user)
 }
 
@@ -342,7 +865,7 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(JWTTokenString, cfg.secret)
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
 		return
@@ -354,8 +877,16 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	chirp, err := cfg.db.GetChirp(r.Context(), chirpId)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Chirp deletion failed", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpId)
+	if err != nil || chirp.DeletedAt.Valid {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
@@ -365,7 +896,25 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), chirp.ID)
+	// Chirps with replies become tombstones so the thread below them stays
+	// reachable; everything else is removed outright.
+	if chirp.ReplyCount > 0 {
+		_, err = qtx.TombstoneChirp(r.Context(), chirp.ID)
+		if err == nil {
+			err = qtx.DeleteChirpRevisions(r.Context(), chirp.ID)
+		}
+	} else {
+		err = qtx.DeleteChirp(r.Context(), chirp.ID)
+		if err == nil && chirp.InReplyTo.Valid {
+			err = qtx.DecrementReplyCount(r.Context(), chirp.InReplyTo.UUID)
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Chirp deletion failed", err)
+		return
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Chirp deletion failed", err)
 		return
@@ -375,34 +924,68 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *apiConfig) upgradeUserHandler(w http.ResponseWriter, r *http.Request) {
-	key, err := auth.GetAPIKey(r.Header)
-	if key != cfg.polkaKey {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading body", err)
+		return
+	}
+
+	err = auth.VerifyWebhookSignature(r.Header, body, cfg.polkaKeys, time.Now())
+	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized to access this endpoint", err)
+		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	requestBody := struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserId uuid.UUID `json:"user_id"`
-		}
-	}{}
-	err = decoder.Decode(&requestBody)
+	event := polkaEvent{}
+	err = json.Unmarshal(body, &event)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Decoding error", err)
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
 		return
 	}
 
-	if requestBody.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	if event.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing event id", errors.New("webhook event has no id"))
 		return
 	}
 
-	_, err = cfg.db.UpgradeUser(r.Context(), requestBody.Data.UserId)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing event", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	n, err := qtx.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		ID:    event.ID,
+		Event: event.Event,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing event", err)
+		return
+	}
+
+	// Redelivery of an event we already applied; acknowledge it so Polka stops retrying.
+	if n == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = applyPolkaEvent(r.Context(), qtx, event, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "User not found", err)
 		return
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing event", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing event", err)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/mailer"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+var errEmailNotVerified = errors.New("email address not verified")
+
+type VerifyEmailParameters struct {
+	Token string `json:"token"`
+}
+
+// sendEmailVerification mails a signed link proving control of email. The
+// token names the address it was sent to, so a link for an address the user
+// has since moved away from stops working.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeEmailToken(userID, email, cfg.keys, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.publicURL + "/verify-email?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your Chirpy email address",
+		Body: fmt.Sprintf("Confirm this address for your Chirpy account by opening this link within %s:\n\n%s\n\n"+
+			"Or send this token to POST /api/email/verify:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			emailVerificationTTL, link, token),
+	})
+}
+
+// notifyEmailChange tells the old address about a requested or completed
+// change, so a hijacked session can't quietly move the account away.
+// Failures are only logged; the change itself has already been accepted.
+func (cfg *apiConfig) notifyEmailChange(ctx context.Context, oldEmail, newEmail string, completed bool) {
+	subject := "Your Chirpy email address is being changed"
+	body := fmt.Sprintf("Someone asked to change the email address on your Chirpy account to %s. "+
+		"It will change once the new address is confirmed.\n\n"+
+		"If this wasn't you, reset your password now.\n", newEmail)
+	if completed {
+		subject = "Your Chirpy email address was changed"
+		body = fmt.Sprintf("The email address on your Chirpy account is now %s.\n\n"+
+			"If this wasn't you, contact support.\n", newEmail)
+	}
+
+	err := cfg.mailer.Send(ctx, mailer.Message{To: oldEmail, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Error notifying %s of email change: %s", oldEmail, err)
+	}
+}
+
+// verifyEmailHandler confirms either the current address or a pending
+// change, depending on which one the token was issued for.
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := VerifyEmailParameters{}
+	err := decoder.Decode(&params)
+	if err != nil || params.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "Token is required", err)
+		return
+	}
+
+	userID, email, err := auth.ValidateEmailToken(params.Token, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	u, err := cfg.db.GetUserFromID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error verifying email", err)
+		return
+	}
+
+	switch email {
+	case u.Email:
+		_, err = cfg.db.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{ID: u.ID, Email: email})
+	case u.PendingEmail.String:
+		_, err = cfg.db.ConfirmEmailChange(r.Context(), database.ConfirmEmailChangeParams{
+			ID:           u.ID,
+			PendingEmail: sql.NullString{String: email, Valid: true},
+		})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			respondWithError(w, http.StatusConflict, "Email already in use", err)
+			return
+		}
+		if err == nil {
+			cfg.notifyEmailChange(r.Context(), u.Email, email, true)
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", errors.New("token is for a superseded address"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error verifying email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerificationHandler re-sends the link for a pending change, or for
+// the current address if it hasn't been confirmed yet.
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	u, err := cfg.db.GetUserFromID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "User not found", err)
+		return
+	}
+
+	email := u.PendingEmail.String
+	if email == "" {
+		if u.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "Email already verified", nil)
+			return
+		}
+		email = u.Email
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), u.ID, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requireVerifiedEmail is checked by actions that are easy to abuse from
+// throwaway accounts, such as posting and reporting.
+func (cfg *apiConfig) requireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	u, err := cfg.db.GetUserFromID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !u.EmailVerifiedAt.Valid {
+		return errEmailNotVerified
+	}
+	return nil
+}
+
+func respondWithEmailVerificationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errEmailNotVerified) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address first", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Error checking email verification", err)
+}
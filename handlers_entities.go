@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/entities"
+)
+
+// resolveEntities parses body and looks up the account behind each mention.
+// Mentions of unknown handles are dropped rather than rejected, the same as
+// any other text that happens to start with an @.
+func resolveEntities(ctx context.Context, q *database.Queries, body string) (entities.Entities, error) {
+	e := entities.Parse(body)
+
+	mentions := e.Mentions[:0]
+	for _, m := range e.Mentions {
+		user, err := q.GetUserByHandle(ctx, m.Handle)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return entities.Entities{}, err
+		}
+		m.UserID = user.ID
+		mentions = append(mentions, m)
+	}
+	e.Mentions = mentions
+
+	return e, nil
+}
+
+// indexChirp replaces the hashtag and mention rows for chirp. It runs
+// whenever a body is written; the search document is a generated column and
+// needs no help.
+func indexChirp(ctx context.Context, q *database.Queries, chirp database.Chirp, e entities.Entities) error {
+	err := q.ClearChirpHashtags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	err = q.ClearChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range e.Hashtags {
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:        chirp.ID,
+			Tag:            h.Tag,
+			ChirpCreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range e.Mentions {
+		err = q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID: chirp.ID,
+			UserID:  m.UserID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) hashtagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", errors.New("empty tag"))
+		return
+	}
+
+	pageSize, cursor, err := parsePageParams(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	chirps, err := cfg.db.ListChirpsByHashtag(r.Context(), database.ListChirpsByHashtagParams{
+		Tag:             tag,
+		BeforeCreatedAt: cursor.CreatedAt,
+		BeforeID:        cursor.ID,
+		ViewerID:        cfg.viewerID(r),
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
+		return
+	}
+
+	page := newChirpsPage(chirps, pageSize)
+	err = cfg.addViewerFlags(r, page.Chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
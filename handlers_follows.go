@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+func (cfg *apiConfig) followHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	followerID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	if followerID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "Users can not follow themselves", errors.New("self follow"))
+		return
+	}
+
+	_, err = cfg.db.GetUserFromID(r.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to follow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	followerID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unfollow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) followersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	pageSize, cursor, err := parsePageParams(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	rows, err := cfg.db.ListFollowers(r.Context(), database.ListFollowersParams{
+		FolloweeID:      userID,
+		BeforeCreatedAt: cursor.CreatedAt,
+		BeforeID:        cursor.ID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving followers", err)
+		return
+	}
+
+	follows := make([]Follow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.FollowerID, FollowedAt: row.CreatedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, newFollowsPage(follows, pageSize))
+}
+
+func (cfg *apiConfig) followingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	pageSize, cursor, err := parsePageParams(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	rows, err := cfg.db.ListFollowing(r.Context(), database.ListFollowingParams{
+		FollowerID:      userID,
+		BeforeCreatedAt: cursor.CreatedAt,
+		BeforeID:        cursor.ID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving followed users", err)
+		return
+	}
+
+	follows := make([]Follow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.FolloweeID, FollowedAt: row.CreatedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, newFollowsPage(follows, pageSize))
+}
+
+func (cfg *apiConfig) timelineHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	pageSize, cursor, err := parsePageParams(r, true)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	chirps, err := cfg.db.ListTimeline(r.Context(), database.ListTimelineParams{
+		FollowerID:      userID,
+		BeforeCreatedAt: cursor.CreatedAt,
+		BeforeID:        cursor.ID,
+		ViewerID:        uuid.NullUUID{UUID: userID, Valid: true},
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving timeline", err)
+		return
+	}
+
+	page := newChirpsPage(chirps, pageSize)
+	err = cfg.addViewerFlags(r, page.Chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving timeline", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func newFollowsPage(follows []Follow, pageSize int32) FollowsPage {
+	page := FollowsPage{Users: follows}
+	if len(follows) > int(pageSize) {
+		page.Users = follows[:pageSize]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeCursor(pageCursor{CreatedAt: last.FollowedAt, ID: last.UserID})
+	}
+	return page
+}
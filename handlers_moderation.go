@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/moderation"
+)
+
+const wordListRefreshInterval = time.Minute
+
+// loadModerationRules reads the word list from MODERATION_WORDS_FILE when it
+// is set, and from the moderation_words table otherwise.
+func (cfg *apiConfig) loadModerationRules(ctx context.Context) ([]moderation.Rule, error) {
+	if cfg.wordListFile != "" {
+		return moderation.LoadRules(cfg.wordListFile)
+	}
+
+	rows, err := cfg.db.ListModerationWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]moderation.Rule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, moderation.Rule{
+			Word:   row.Word,
+			Action: moderation.Action(row.Action),
+		})
+	}
+	return rules, nil
+}
+
+func (cfg *apiConfig) reloadWordList(ctx context.Context) error {
+	rules, err := cfg.loadModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+	return cfg.wordList.Replace(rules)
+}
+
+// refreshWordList picks up changes made by other instances or to the word
+// list file.
+func (cfg *apiConfig) refreshWordList(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := cfg.reloadWordList(context.Background())
+		if err != nil {
+			log.Printf("Error reloading moderation word list: %s", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) listModerationWordsHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, cfg.wordList.Rules())
+}
+
+func (cfg *apiConfig) putModerationWordHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.wordListFile != "" {
+		respondWithError(w, http.StatusConflict, "Word list is managed by file", errors.New("MODERATION_WORDS_FILE is set"))
+		return
+	}
+
+	word, err := moderation.NormalizeWord(r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Action string `json:"action"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	action, err := moderation.ParseAction(requestBody.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid action", err)
+		return
+	}
+
+	_, err = cfg.db.UpsertModerationWord(r.Context(), database.UpsertModerationWordParams{
+		Word:   word,
+		Action: string(action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving word", err)
+		return
+	}
+
+	err = cfg.reloadWordList(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reloading word list", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, moderation.Rule{Word: word, Action: action})
+}
+
+func (cfg *apiConfig) deleteModerationWordHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.wordListFile != "" {
+		respondWithError(w, http.StatusConflict, "Word list is managed by file", errors.New("MODERATION_WORDS_FILE is set"))
+		return
+	}
+
+	word, err := moderation.NormalizeWord(r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word", err)
+		return
+	}
+
+	n, err := cfg.db.DeleteModerationWord(r.Context(), word)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting word", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Word not found", errors.New("word is not listed"))
+		return
+	}
+
+	err = cfg.reloadWordList(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reloading word list", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) listChirpFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.db.ListChirpFlags(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving flags", err)
+		return
+	}
+
+	flags := make([]ChirpFlag, 0, len(rows))
+	for _, row := range rows {
+		flags = append(flags, ChirpFlag{
+			ChirpID:   row.ChirpID,
+			Words:     row.Words,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, flags)
+}
+
+// flagChirp queues chirp for review when the filter asked for it.
+func flagChirp(ctx context.Context, q *database.Queries, chirp database.Chirp, result moderation.Result) error {
+	if !result.Has(moderation.ActionFlag) {
+		return nil
+	}
+	return q.FlagChirp(ctx, database.FlagChirpParams{
+		ChirpID: chirp.ID,
+		Words:   result.Words(moderation.ActionFlag),
+	})
+}
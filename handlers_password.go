@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/emailaddr"
+	"github.com/migomi3/internal/mailer"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// passwordResetSendTimeout bounds the background work started by a
+	// forgot-password request.
+	passwordResetSendTimeout = time.Minute
+)
+
+var errInvalidResetToken = errors.New("reset token is unknown, used or expired")
+
+type ForgotPasswordParameters struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordParameters struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPasswordHandler always answers 202 so the endpoint can't be used to
+// find out which emails have accounts. The lookup and the email happen
+// after the response, so its timing doesn't tell either; failures are only
+// logged.
+func (cfg *apiConfig) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := ForgotPasswordParameters{}
+	err := decoder.Decode(&params)
+	if err != nil || params.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "Email is required", err)
+		return
+	}
+
+	email, err := emailaddr.Normalize(params.Email)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), passwordResetSendTimeout)
+		go func() {
+			defer cancel()
+			cfg.requestPasswordReset(ctx, email)
+		}()
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requestPasswordReset emails a reset link if email has an account.
+func (cfg *apiConfig) requestPasswordReset(ctx context.Context, email string) {
+	u, err := cfg.db.GetUser(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up user for password reset: %s", err)
+		return
+	}
+
+	err = cfg.sendPasswordReset(ctx, u)
+	if err != nil {
+		log.Printf("Error sending password reset for user %s: %s", u.ID, err)
+	}
+}
+
+// sendPasswordReset replaces any outstanding reset tokens for u with a new
+// one and emails it. Only the token's hash is stored.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, u database.User) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.UsePasswordResetTokens(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = qtx.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    u.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	link := cfg.publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"To choose a new password, open this link within %s:\n\n%s\n\n"+
+			"Or send this token to POST /api/password/reset:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n",
+			passwordResetTTL, link, token),
+	})
+}
+
+func (cfg *apiConfig) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := ResetPasswordParameters{}
+	err := decoder.Decode(&params)
+	if err != nil || params.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "Token is required", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Locking the token row means two concurrent resets with the same token
+	// can't both succeed.
+	t, err := qtx.GetPasswordResetTokenForUpdate(r.Context(), auth.HashToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (t.UsedAt.Valid || time.Now().After(t.ExpiresAt))) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token", errInvalidResetToken)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	u, err := qtx.GetUserFromID(r.Context(), t.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	// A refused password leaves the token unused, so the user can try
+	// again with the same link.
+	fields, err := passwordFieldErrors(cfg.passwordPolicy, params.Password, u.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+		return
+	}
+	if len(fields) > 0 {
+		respondWithValidationErrors(w, fields)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Password Hashing failed", err)
+		return
+	}
+
+	_, err = qtx.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		ID:             t.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	err = qtx.UsePasswordResetTokens(r.Context(), t.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	err = qtx.RevokeAllSessions(r.Context(), t.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resetting password", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+// reactionUpdate records or removes one user's reaction to a chirp and
+// keeps the chirp's counter in step. It runs inside a transaction.
+type reactionUpdate func(ctx context.Context, q *database.Queries, userID, chirpID uuid.UUID) error
+
+func (cfg *apiConfig) likeHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateReaction(w, r, func(ctx context.Context, q *database.Queries, userID, chirpID uuid.UUID) error {
+		n, err := q.LikeChirp(ctx, database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+		if err != nil || n == 0 {
+			return err
+		}
+		return q.AdjustLikeCount(ctx, database.AdjustLikeCountParams{Delta: 1, ID: chirpID})
+	})
+}
+
+func (cfg *apiConfig) unlikeHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateReaction(w, r, func(ctx context.Context, q *database.Queries, userID, chirpID uuid.UUID) error {
+		n, err := q.UnlikeChirp(ctx, database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+		if err != nil || n == 0 {
+			return err
+		}
+		return q.AdjustLikeCount(ctx, database.AdjustLikeCountParams{Delta: -1, ID: chirpID})
+	})
+}
+
+func (cfg *apiConfig) rechirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateReaction(w, r, func(ctx context.Context, q *database.Queries, userID, chirpID uuid.UUID) error {
+		n, err := q.Rechirp(ctx, database.RechirpParams{UserID: userID, ChirpID: chirpID})
+		if err != nil || n == 0 {
+			return err
+		}
+		return q.AdjustRechirpCount(ctx, database.AdjustRechirpCountParams{Delta: 1, ID: chirpID})
+	})
+}
+
+func (cfg *apiConfig) undoRechirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.updateReaction(w, r, func(ctx context.Context, q *database.Queries, userID, chirpID uuid.UUID) error {
+		n, err := q.UndoRechirp(ctx, database.UndoRechirpParams{UserID: userID, ChirpID: chirpID})
+		if err != nil || n == 0 {
+			return err
+		}
+		return q.AdjustRechirpCount(ctx, database.AdjustRechirpCountParams{Delta: -1, ID: chirpID})
+	})
+}
+
+func (cfg *apiConfig) updateReaction(w http.ResponseWriter, r *http.Request, update reactionUpdate) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Locking the chirp serialises concurrent reactions so the counter and
+	// the reaction rows can't drift apart.
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", errors.New("author is shadowbanned"))
+		return
+	}
+
+	err = update(r.Context(), qtx, userID, chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addViewerFlags marks which chirps the caller has liked or rechirped. The
+// flags are only set when the request carries a valid JWT; anonymous
+// callers get the chirps unchanged.
+func (cfg *apiConfig) addViewerFlags(r *http.Request, chirps []Chirp) error {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return nil
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+
+	liked, err := cfg.db.ListLikedChirpIDs(r.Context(), database.ListLikedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	rechirped, err := cfg.db.ListRechirpedChirpIDs(r.Context(), database.ListRechirpedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	setViewerFlags(chirps, liked, rechirped)
+	return nil
+}
+
+func setViewerFlags(chirps []Chirp, liked, rechirped []uuid.UUID) {
+	likedSet := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	rechirpedSet := make(map[uuid.UUID]bool, len(rechirped))
+	for _, id := range rechirped {
+		rechirpedSet[id] = true
+	}
+
+	for i := range chirps {
+		likedByMe := likedSet[chirps[i].ID]
+		rechirpedByMe := rechirpedSet[chirps[i].ID]
+		chirps[i].LikedByMe = &likedByMe
+		chirps[i].RechirpedByMe = &rechirpedByMe
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSetViewerFlags(t *testing.T) {
+	liked, rechirped, neither := uuid.New(), uuid.New(), uuid.New()
+	chirps := []Chirp{{ID: liked}, {ID: rechirped}, {ID: neither}}
+
+	setViewerFlags(chirps, []uuid.UUID{liked}, []uuid.UUID{rechirped})
+
+	expected := []struct {
+		likedByMe     bool
+		rechirpedByMe bool
+	}{
+		{true, false},
+		{false, true},
+		{false, false},
+	}
+
+	for i, want := range expected {
+		c := chirps[i]
+		if c.LikedByMe == nil || c.RechirpedByMe == nil {
+			t.Fatalf("chirp %d: flags not set", i)
+		}
+		if *c.LikedByMe != want.likedByMe || *c.RechirpedByMe != want.rechirpedByMe {
+			t.Errorf("chirp %d: got liked=%v rechirped=%v, want liked=%v rechirped=%v",
+				i, *c.LikedByMe, *c.RechirpedByMe, want.likedByMe, want.rechirpedByMe)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+const (
+	reportOpen      = "open"
+	reportDismissed = "dismissed"
+	reportActioned  = "actioned"
+
+	resolutionDismiss = "dismiss"
+	resolutionHide    = "hide"
+	resolutionDelete  = "delete"
+
+	maxReportDetailsLength = 1000
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "self_harm", "misinformation", "other"}
+
+func (cfg *apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	err = cfg.requireVerifiedEmail(r.Context(), userID)
+	if err != nil {
+		respondWithEmailVerificationError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	if !slices.Contains(reportReasons, requestBody.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Invalid reason", fmt.Errorf("unknown report reason %q", requestBody.Reason))
+		return
+	}
+	if len(requestBody.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Details are too long", errors.New("report details exceed maximum length"))
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can not report your own chirp", errors.New("reporter is the author"))
+		return
+	}
+
+	n, err := cfg.db.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    chirp.ID,
+		ReporterID: userID,
+		Reason:     requestBody.Reason,
+		Details:    requestBody.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reporting chirp", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, "Chirp already reported", errors.New("open report exists"))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = reportOpen
+	}
+	if status != reportOpen && status != reportDismissed && status != reportActioned {
+		respondWithError(w, http.StatusBadRequest, "Invalid status", fmt.Errorf("unknown report status %q", status))
+		return
+	}
+
+	// The queue is worked oldest first.
+	pageSize, cursor, err := parsePageParams(r, false)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	rows, err := cfg.db.ListReports(r.Context(), database.ListReportsParams{
+		Status:         status,
+		AfterCreatedAt: cursor.CreatedAt,
+		AfterID:        cursor.ID,
+		PageSize:       pageSize + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving reports", err)
+		return
+	}
+
+	page := ReportsPage{
+		Reports: make([]Report, 0, len(rows)),
+	}
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	for _, row := range rows {
+		report := Report{
+			ID:         row.ID,
+			ChirpID:    row.ChirpID,
+			ReporterID: row.ReporterID,
+			Reason:     row.Reason,
+			Details:    row.Details,
+			Status:     row.Status,
+			CreatedAt:  row.CreatedAt,
+			Resolution: row.Resolution.String,
+			AuthorID:   row.AuthorID,
+			ChirpBody:  row.Body,
+			Hidden:     row.HiddenAt.Valid,
+			Deleted:    row.DeletedAt.Valid,
+		}
+		if row.ResolvedBy.Valid {
+			report.ResolvedBy = &row.ResolvedBy.UUID
+		}
+		if row.ResolvedAt.Valid {
+			report.ResolvedAt = &row.ResolvedAt.Time
+		}
+		page.Reports = append(page.Reports, report)
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// resolveReportHandler applies a moderator's decision. Hiding or deleting
+// settles every open report against the chirp; deleting also warns the
+// author.
+func (cfg *apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	moderatorID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Action string `json:"action"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	action := requestBody.Action
+	if action != resolutionDismiss && action != resolutionHide && action != resolutionDelete {
+		respondWithError(w, http.StatusBadRequest, "Invalid action", fmt.Errorf("unknown resolution %q", action))
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err := qtx.GetReportForUpdate(r.Context(), reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving report", err)
+		return
+	}
+
+	if report.Status != reportOpen {
+		respondWithError(w, http.StatusConflict, "Report already resolved", fmt.Errorf("report is %s", report.Status))
+		return
+	}
+
+	moderator := uuid.NullUUID{UUID: moderatorID, Valid: true}
+
+	if action == resolutionDismiss {
+		err = qtx.DismissReport(r.Context(), database.DismissReportParams{
+			ID:         report.ID,
+			ResolvedBy: moderator,
+		})
+	} else {
+		err = applyReportAction(r.Context(), qtx, report, action, moderator)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving report", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving report", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func applyReportAction(ctx context.Context, q *database.Queries, report database.Report, action string, moderator uuid.NullUUID) error {
+	chirp, err := q.GetChirpForUpdate(ctx, report.ChirpID)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case resolutionHide:
+		err = q.HideChirp(ctx, chirp.ID)
+	case resolutionDelete:
+		// Moderator deletions always leave a tombstone so the reports
+		// against the chirp survive as a record of the decision.
+		if !chirp.DeletedAt.Valid {
+			_, err = q.TombstoneChirp(ctx, chirp.ID)
+			if err == nil {
+				err = q.DeleteChirpRevisions(ctx, chirp.ID)
+			}
+		}
+		if err == nil {
+			_, err = q.CreateWarning(ctx, database.CreateWarningParams{
+				UserID:      chirp.UserID,
+				ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+				ModeratorID: moderator,
+				Reason:      report.Reason,
+			})
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ActionChirpReports(ctx, database.ActionChirpReportsParams{
+		ChirpID:    chirp.ID,
+		Resolution: sql.NullString{String: action, Valid: true},
+		ResolvedBy: moderator,
+	})
+	return err
+}
+
+func (cfg *apiConfig) warningsHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	rows, err := cfg.db.ListWarnings(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving warnings", err)
+		return
+	}
+
+	warnings := make([]Warning, 0, len(rows))
+	for _, row := range rows {
+		warning := Warning{
+			ID:        row.ID,
+			Reason:    row.Reason,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.ChirpID.Valid {
+			warning.ChirpID = &row.ChirpID.UUID
+		}
+		warnings = append(warnings, warning)
+	}
+
+	respondWithJSON(w, http.StatusOK, warnings)
+}
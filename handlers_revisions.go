@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+const defaultChirpEditWindow = 15 * time.Minute
+
+func (cfg *apiConfig) editChirpHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	requestBody := struct {
+		Body string `json:"body"`
+	}{}
+	err = decoder.Decode(&requestBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	limits, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking entitlements", err)
+		return
+	}
+
+	if !limits.CanEditChirps {
+		respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red", errors.New("plan does not allow editing"))
+		return
+	}
+
+	moderated, err := prepareChirpBody(requestBody.Body, limits.MaxChirpLength, cfg.wordList)
+	if err != nil {
+		respondWithChirpBodyError(w, err)
+		return
+	}
+	body := moderated.Body
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Can not edit chirp of another user", errors.New("user is not the author"))
+		return
+	}
+
+	if time.Since(chirp.CreatedAt) > cfg.chirpEditWindow {
+		respondWithError(w, http.StatusForbidden, "Edit window has closed", fmt.Errorf("chirp is older than %s", cfg.chirpEditWindow))
+		return
+	}
+
+	// The revision keeps the body being replaced along with when it was written.
+	writtenAt := chirp.CreatedAt
+	if chirp.EditedAt.Valid {
+		writtenAt = chirp.EditedAt.Time
+	}
+
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: writtenAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving revision", err)
+		return
+	}
+
+	chirpEntities, err := resolveEntities(r.Context(), qtx, body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resolving mentions", err)
+		return
+	}
+
+	entitiesJSON, err := json.Marshal(chirpEntities)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:       chirp.ID,
+		Body:     body,
+		Entities: entitiesJSON,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+
+	err = indexChirp(r.Context(), qtx, updated, chirpEntities)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+
+	err = flagChirp(r.Context(), qtx, updated, moderated)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error editing chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpFromDB(updated))
+}
+
+func (cfg *apiConfig) chirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, cfg.viewerID(r))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving revisions", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", errors.New("author is shadowbanned"))
+		return
+	}
+
+	rows, err := cfg.db.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving revisions", err)
+		return
+	}
+
+	revisions := make([]ChirpRevision, 0, len(rows))
+	for _, row := range rows {
+		revisions = append(revisions, ChirpRevision{
+			ID:         row.ID,
+			Body:       row.Body,
+			CreatedAt:  row.CreatedAt,
+			ReplacedAt: row.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, revisions)
+}
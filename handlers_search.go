@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/database"
+)
+
+const maxSearchQueryLength = 256
+
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing search query", errors.New("q is required"))
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		respondWithError(w, http.StatusBadRequest, "Search query is too long", errors.New("q exceeds maximum length"))
+		return
+	}
+
+	pageSize, err := parsePageSize(query.Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	cursor := firstSearchCursor()
+	if c := query.Get("cursor"); c != "" {
+		cursor, err = decodeSearchCursor(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+			return
+		}
+	}
+
+	params := database.SearchChirpsParams{
+		Query:           q,
+		Since:           time.Time{},
+		Until:           firstPageCursor(true).CreatedAt,
+		BeforeRank:      cursor.Rank,
+		BeforeCreatedAt: cursor.CreatedAt,
+		BeforeID:        cursor.ID,
+		ViewerID:        cfg.viewerID(r),
+		PageSize:        pageSize + 1,
+	}
+
+	if a := query.Get("author_id"); a != "" {
+		id, err := uuid.Parse(a)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	if s := query.Get("since"); s != "" {
+		params.Since, err = parseSearchDate(s, false)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since date", err)
+			return
+		}
+	}
+
+	if u := query.Get("until"); u != "" {
+		params.Until, err = parseSearchDate(u, true)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until date", err)
+			return
+		}
+	}
+
+	rows, err := cfg.db.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error searching chirps", err)
+		return
+	}
+
+	page := SearchPage{
+		Results: make([]SearchResult, 0, len(rows)),
+	}
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeSearchCursor(searchCursor{
+			Rank:       last.Rank,
+			pageCursor: pageCursor{CreatedAt: last.CreatedAt, ID: last.ID},
+		})
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromDB(database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			EditedAt:     row.EditedAt,
+			InReplyTo:    row.InReplyTo,
+			ReplyCount:   row.ReplyCount,
+			DeletedAt:    row.DeletedAt,
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+			Entities:     row.Entities,
+			HiddenAt:     row.HiddenAt,
+		}))
+	}
+
+	err = cfg.addViewerFlags(r, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error searching chirps", err)
+		return
+	}
+
+	for i, row := range rows {
+		page.Results = append(page.Results, SearchResult{
+			Chirp:   chirps[i],
+			Rank:    row.Rank,
+			Snippet: highlightSnippet(row.Snippet),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// snippetHighlighter turns the match delimiters SearchChirps puts in a
+// snippet into markup, after the text around them has been escaped.
+var snippetHighlighter = strings.NewReplacer("\uE000", "<mark>", "\uE001", "</mark>")
+
+func highlightSnippet(snippet string) string {
+	return snippetHighlighter.Replace(html.EscapeString(snippet))
+}
+
+// parseSearchDate accepts either an RFC 3339 timestamp or a plain date. A
+// plain date used as an upper bound covers the whole of that day.
+func parseSearchDate(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{
+			name:    "Match",
+			snippet: "learning \uE000go\uE001 today",
+			want:    "learning <mark>go</mark> today",
+		},
+		{
+			name:    "Markup in body is escaped",
+			snippet: "<img src=x onerror=alert(1)> \uE000go\uE001 & <mark>",
+			want:    "&lt;img src=x onerror=alert(1)&gt; <mark>go</mark> &amp; &lt;mark&gt;",
+		},
+		{
+			name:    "Quotes",
+			snippet: "\"\uE000go\uE001\" isn't",
+			want:    "&#34;<mark>go</mark>&#34; isn&#39;t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.snippet); got != tt.want {
+				t.Errorf("highlightSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
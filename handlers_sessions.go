@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+)
+
+func (cfg *apiConfig) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	cfg.respondWithSessions(w, r, userID)
+}
+
+func (cfg *apiConfig) adminListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	cfg.respondWithSessions(w, r, userID)
+}
+
+const (
+	defaultAuthEventsLimit = 100
+	maxAuthEventsLimit     = 500
+)
+
+// adminAuthEventsHandler lists a user's most recent login attempts for
+// support staff investigating a lockout or a suspected takeover.
+func (cfg *apiConfig) adminAuthEventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	limit := defaultAuthEventsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxAuthEventsLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+	}
+
+	rows, err := cfg.db.ListAuthEventsForUser(r.Context(), database.ListAuthEventsForUserParams{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving auth events", err)
+		return
+	}
+
+	events := make([]AuthEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, AuthEvent{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			Event:     row.Event,
+			Email:     row.Email,
+			IPAddress: row.IpAddress,
+			UserAgent: row.UserAgent,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) respondWithSessions(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	rows, err := cfg.db.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving sessions", err)
+		return
+	}
+
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:         row.FamilyID,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	n, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke session", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Session not found", errors.New("no live tokens in session"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) revokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	err = cfg.db.RevokeAllSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
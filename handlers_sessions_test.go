@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+)
+
+func TestAdminListSessionsRequiresAdmin(t *testing.T) {
+	keys, otherKeys := testKeyring(t), testKeyring(t)
+
+	cfg := &apiConfig{keys: keys}
+	handler := cfg.adminHandler()
+
+	userToken, err := auth.MakeJWT(uuid.New(), auth.RoleUser, keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedAdminToken, err := auth.MakeJWT(uuid.New(), auth.RoleAdmin, otherKeys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{name: "No token", wantCode: http.StatusUnauthorized},
+		{name: "Token from another issuer", header: "Bearer " + forgedAdminToken, wantCode: http.StatusUnauthorized},
+		{name: "Regular user", header: "Bearer " + userToken, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/users/"+uuid.NewString()+"/sessions", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
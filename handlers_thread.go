@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/database"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+	maxThreadAncestors = 50
+	maxThreadReplies   = 500
+)
+
+func (cfg *apiConfig) threadHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id", err)
+		return
+	}
+
+	depth := defaultThreadDepth
+	if d := r.URL.Query().Get("depth"); d != "" {
+		depth, err = strconv.Atoi(d)
+		if err != nil || depth < 0 || depth > maxThreadDepth {
+			respondWithError(w, http.StatusBadRequest, "Invalid depth", err)
+			return
+		}
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	viewerID := cfg.viewerID(r)
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving thread", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", errors.New("author is shadowbanned"))
+		return
+	}
+
+	ancestors, err := cfg.db.ListChirpAncestors(r.Context(), database.ListChirpAncestorsParams{
+		ChirpID:  chirpID,
+		MaxDepth: maxThreadAncestors,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving thread", err)
+		return
+	}
+
+	var descendants []database.Chirp
+	if depth > 0 {
+		descendants, err = cfg.db.ListChirpDescendants(r.Context(), database.ListChirpDescendantsParams{
+			ChirpID:    chirpID,
+			MaxDepth:   int32(depth),
+			MaxReplies: maxThreadReplies,
+			ViewerID:   viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving thread", err)
+			return
+		}
+	}
+
+	thread := Thread{
+		Ancestors: make([]Chirp, 0, len(ancestors)),
+		Chirp:     buildThreadTree(chirp, descendants),
+	}
+	for _, a := range ancestors {
+		thread.Ancestors = append(thread.Ancestors, chirpFromDB(a))
+	}
+
+	respondWithJSON(w, http.StatusOK, thread)
+}
+
+// buildThreadTree nests descendants under root by their in_reply_to links.
+// Replies keep the order they were fetched in.
+func buildThreadTree(root database.Chirp, descendants []database.Chirp) ThreadNode {
+	children := make(map[uuid.UUID][]database.Chirp)
+	for _, c := range descendants {
+		if c.InReplyTo.Valid {
+			children[c.InReplyTo.UUID] = append(children[c.InReplyTo.UUID], c)
+		}
+	}
+
+	var build func(c database.Chirp) ThreadNode
+	build = func(c database.Chirp) ThreadNode {
+		node := ThreadNode{
+			Chirp:   chirpFromDB(c),
+			Replies: make([]ThreadNode, 0, len(children[c.ID])),
+		}
+		for _, child := range children[c.ID] {
+			node.Replies = append(node.Replies, build(child))
+		}
+		return node
+	}
+
+	return build(root)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/database"
+)
+
+func TestBuildThreadTree(t *testing.T) {
+	root := database.Chirp{ID: uuid.New(), Body: "root"}
+	replyTo := func(parent database.Chirp, body string) database.Chirp {
+		return database.Chirp{
+			ID:        uuid.New(),
+			Body:      body,
+			InReplyTo: uuid.NullUUID{UUID: parent.ID, Valid: true},
+		}
+	}
+
+	first := replyTo(root, "first")
+	second := replyTo(root, "second")
+	nested := replyTo(first, "nested")
+	orphan := replyTo(database.Chirp{ID: uuid.New()}, "orphan")
+
+	tree := buildThreadTree(root, []database.Chirp{first, second, nested, orphan})
+
+	if tree.Body != "root" {
+		t.Fatalf("root body = %q, want %q", tree.Body, "root")
+	}
+	if len(tree.Replies) != 2 {
+		t.Fatalf("root has %d replies, want 2", len(tree.Replies))
+	}
+	if tree.Replies[0].Body != "first" || tree.Replies[1].Body != "second" {
+		t.Errorf("replies out of order: %q, %q", tree.Replies[0].Body, tree.Replies[1].Body)
+	}
+	if len(tree.Replies[0].Replies) != 1 || tree.Replies[0].Replies[0].Body != "nested" {
+		t.Errorf("nested reply missing under first reply")
+	}
+	if tree.Replies[1].Replies == nil {
+		t.Errorf("leaf replies should be an empty slice, not nil")
+	}
+}
+
+// A shadowbanned reply in the middle of a thread is pruned with its branch
+// by ListChirpDescendants, so the tree must come out whole from what is
+// left, and a row whose parent was filtered must never surface on its own.
+func TestBuildThreadTreeFilteredReply(t *testing.T) {
+	root := database.Chirp{ID: uuid.New(), Body: "root"}
+	replyTo := func(parent database.Chirp, body string) database.Chirp {
+		return database.Chirp{
+			ID:        uuid.New(),
+			Body:      body,
+			InReplyTo: uuid.NullUUID{UUID: parent.ID, Valid: true},
+		}
+	}
+
+	visible := replyTo(root, "visible")
+	hidden := replyTo(root, "hidden")
+	underVisible := replyTo(visible, "under visible")
+	underHidden := replyTo(hidden, "under hidden")
+
+	tests := []struct {
+		name        string
+		descendants []database.Chirp
+	}{
+		{name: "Pruned by the query", descendants: []database.Chirp{visible, underVisible}},
+		{name: "Reply under a filtered parent", descendants: []database.Chirp{visible, underVisible, underHidden}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildThreadTree(root, tt.descendants)
+
+			if len(tree.Replies) != 1 || tree.Replies[0].Body != "visible" {
+				t.Fatalf("root replies = %+v, want only the visible reply", tree.Replies)
+			}
+			if len(tree.Replies[0].Replies) != 1 || tree.Replies[0].Replies[0].Body != "under visible" {
+				t.Errorf("visible branch = %+v, want its nested reply", tree.Replies[0].Replies)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/totp"
+)
+
+const (
+	totpIssuer        = "Chirpy"
+	totpQRCodeSize    = 256
+	twoFactorLoginTTL = 5 * time.Minute
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// SecondFactorParameters carries either a current TOTP code or one of the
+// account's recovery codes.
+type SecondFactorParameters struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorLoginParameters struct {
+	ChallengeToken string `json:"challenge_token"`
+	SecondFactorParameters
+}
+
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	cred, err := cfg.db.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cred.ConfirmedAt.Valid, nil
+}
+
+func (cfg *apiConfig) respondWithTwoFactorChallenge(w http.ResponseWriter, u database.User) {
+	token, err := auth.MakeChallengeToken(u.ID, cfg.keys, twoFactorLoginTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating challenge token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         time.Now().Add(twoFactorLoginTTL),
+	})
+}
+
+// verifySecondFactor checks a TOTP code or spends a recovery code. It runs
+// inside a transaction: the credential row is locked so the same TOTP code
+// can't be accepted twice, even by concurrent requests.
+func verifySecondFactor(ctx context.Context, q *database.Queries, userID uuid.UUID, params SecondFactorParameters) error {
+	if params.Code != "" {
+		cred, err := q.GetTOTPCredentialForUpdate(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidSecondFactor
+		}
+		if err != nil {
+			return err
+		}
+		if !cred.ConfirmedAt.Valid {
+			return errInvalidSecondFactor
+		}
+
+		step, ok := totp.Validate(cred.Secret, params.Code, time.Now())
+		if !ok || step <= cred.LastUsedStep {
+			return errInvalidSecondFactor
+		}
+
+		return q.UseTOTPStep(ctx, database.UseTOTPStepParams{UserID: userID, LastUsedStep: step})
+	}
+
+	if params.RecoveryCode != "" {
+		n, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(params.RecoveryCode),
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	return errInvalidSecondFactor
+}
+
+// replaceRecoveryCodes invalidates any existing recovery codes and returns a
+// fresh set. Only their hashes are stored, so this is the one chance to
+// show them.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]string, error) {
+	err := q.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.MakeRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		err = q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func respondWithSecondFactorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidSecondFactor) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Error checking two-factor code", err)
+}
+
+// enrollTOTPHandler starts (or restarts) enrollment with a new secret. The
+// secret has no effect until confirmTOTPHandler sees a code generated from
+// it.
+func (cfg *apiConfig) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	u, err := cfg.db.GetUserFromID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "User not found", err)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating TOTP secret", err)
+		return
+	}
+
+	_, err = cfg.db.StartTOTPEnrollment(r.Context(), database.StartTOTPEnrollmentParams{
+		UserID: u.ID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication already enabled", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting enrollment", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, u.Email, secret),
+		QRCodeURL:       "/api/2fa/totp/qr.png",
+	})
+}
+
+// totpQRHandler renders the pending enrollment's provisioning URI as a PNG.
+// Once enrollment is confirmed the secret is never shown again.
+func (cfg *apiConfig) totpQRHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	u, err := cfg.db.GetUserFromID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "User not found", err)
+		return
+	}
+
+	cred, err := cfg.db.GetTOTPCredential(r.Context(), u.ID)
+	if err != nil || cred.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "No pending enrollment", err)
+		return
+	}
+
+	png, err := totp.QRCode(totp.ProvisioningURI(totpIssuer, u.Email, cred.Secret), totpQRCodeSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rendering QR code", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
+
+func (cfg *apiConfig) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := SecondFactorParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error confirming enrollment", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	cred, err := qtx.GetTOTPCredentialForUpdate(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "No pending enrollment", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error confirming enrollment", err)
+		return
+	}
+	if cred.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication already enabled", nil)
+		return
+	}
+
+	step, ok := totp.Validate(cred.Secret, params.Code, time.Now())
+	if !ok {
+		respondWithSecondFactorError(w, errInvalidSecondFactor)
+		return
+	}
+
+	err = qtx.ConfirmTOTP(r.Context(), database.ConfirmTOTPParams{UserID: userID, LastUsedStep: step})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error confirming enrollment", err)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating recovery codes", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error confirming enrollment", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodes{Codes: codes})
+}
+
+// recoveryCodesHandler swaps the recovery codes for a new set. It needs a
+// current TOTP code, not a recovery code, so losing the codes can't be used
+// to mint more.
+func (cfg *apiConfig) recoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := SecondFactorParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+	params.RecoveryCode = ""
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating recovery codes", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = verifySecondFactor(r.Context(), qtx, userID, params)
+	if err != nil {
+		respondWithSecondFactorError(w, err)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating recovery codes", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating recovery codes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodes{Codes: codes})
+}
+
+func (cfg *apiConfig) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := SecondFactorParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = verifySecondFactor(r.Context(), qtx, userID, params)
+	if err != nil {
+		respondWithSecondFactorError(w, err)
+		return
+	}
+
+	err = qtx.DeleteTOTPCredential(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loginTwoFactorHandler finishes a login that loginHandler answered with a
+// challenge token.
+func (cfg *apiConfig) loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := TwoFactorLoginParameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Decoding error", err)
+		return
+	}
+
+	userID, err := auth.ValidateChallengeToken(params.ChallengeToken, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	// Six-digit codes are guessable without a limit, so the second factor
+	// is throttled per account just like the password.
+	attempt := newLoginAttempt(r, "2fa:"+userID.String())
+	wait, err := cfg.allowLogin(r.Context(), attempt)
+	if err != nil {
+		cfg.recordAuthEvent(r, authEventLoginThrottled, uuid.NullUUID{UUID: userID, Valid: true}, "")
+		respondWithLoginThrottleError(w, wait, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking two-factor code", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	u, err := qtx.GetUserFromID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	err = verifySecondFactor(r.Context(), qtx, userID, params.SecondFactorParameters)
+	if errors.Is(err, errInvalidSecondFactor) {
+		cfg.failLogin(r.Context(), attempt)
+		cfg.recordAuthEvent(r, authEventTwoFactorFailed, uuid.NullUUID{UUID: u.ID, Valid: true}, u.Email)
+	}
+	if err != nil {
+		respondWithSecondFactorError(w, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking two-factor code", err)
+		return
+	}
+	cfg.succeedLogin(r.Context(), attempt)
+	cfg.recordAuthEvent(r, authEventTwoFactorSucceeded, uuid.NullUUID{UUID: u.ID, Valid: true}, u.Email)
+
+	// The account may have been actioned since the password step.
+	err = checkAccountStatus(u, time.Now())
+	if err != nil {
+		respondWithAccountStatusError(w, u, err)
+		return
+	}
+
+	cfg.startSession(w, r, u)
+}
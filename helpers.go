@@ -2,10 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
-	"slices"
 	"strings"
+
+	"github.com/migomi3/internal/charcount"
+	"github.com/migomi3/internal/moderation"
+	"github.com/migomi3/internal/pwpolicy"
 )
 
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
@@ -23,6 +28,33 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 	})
 }
 
+func respondWithValidationErrors(w http.ResponseWriter, fields []FieldError) {
+	respondWithJSON(w, http.StatusBadRequest, ValidationErrors{
+		Error:  "Validation failed",
+		Fields: fields,
+	})
+}
+
+// passwordFieldErrors checks password against policy for the account at
+// email.
+func passwordFieldErrors(policy pwpolicy.Policy, password, email string) ([]FieldError, error) {
+	violations, err := policy.Check(password, email)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]FieldError, 0, len(violations))
+	for _, v := range violations {
+		fields = append(fields, FieldError{Field: "password", Code: v.Code, Message: v.Message})
+	}
+	return fields, nil
+}
+
+var (
+	invalidEmailField  = FieldError{Field: "email", Code: "invalid", Message: "Email address is not valid"}
+	invalidHandleField = FieldError{Field: "handle", Code: "invalid", Message: "Handle must be 3 to 30 letters, digits or underscores"}
+)
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
@@ -35,15 +67,55 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(dat)
 }
 
-func cleanMessage(body string) string {
-	content := strings.Split(body, " ")
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
+// maxChirpBytes bounds the stored body independently of plan limits, since
+// URLs count for a fixed weight however long they are.
+const maxChirpBytes = 4096
 
-	for i, word := range content {
-		if slices.Contains(badWords, strings.ToLower(word)) {
-			content[i] = "****"
-		}
+var (
+	errChirpTooLong  = errors.New("chirp exceeds character limit")
+	errChirpRejected = errors.New("chirp contains prohibited words")
+)
+
+// prepareChirpBody runs the checks shared by creating, editing and
+// validating a chirp. The returned result's Body is what should be stored,
+// and its length is measured after moderation so it matches what readers
+// see.
+func prepareChirpBody(body string, maxLength int, filter moderation.Filter) (moderation.Result, error) {
+	result := filter.Check(body)
+	if result.Has(moderation.ActionReject) {
+		return result, errChirpRejected
 	}
 
-	return strings.Join(content, " ")
+	if len(result.Body) > maxChirpBytes || charcount.Count(result.Body) > maxLength {
+		return result, errChirpTooLong
+	}
+
+	return result, nil
+}
+
+func respondWithChirpBodyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errChirpRejected) {
+		respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited words", err)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Message exceeds character limit", err)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// splitList parses a comma separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
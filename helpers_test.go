@@ -1,37 +1,67 @@
 package main
 
 import (
-	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/migomi3/internal/moderation"
 )
 
-func TestCleanMessage(t *testing.T) {
+func TestClientIP(t *testing.T) {
 	testCases := []struct {
-		input    string
-		expected string
+		remoteAddr string
+		expected   string
 	}{
-		{"test", "test"},
-		{"Test", "Test"},
-		{"kerfuffle", "****"},
-		{"fucking kerfuffle", "fucking ****"},
-		{"testing Sharbert", "testing ****"},
-		{"uppercase Fornax", "uppercase ****"},
-		{"Fornax!", "Fornax!"},
-		{"sharbert, ", "sharbert, "},
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"192.0.2.1", "192.0.2.1"},
 	}
 
 	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("Running Input: %s\n", tc.input), func(t *testing.T) {
-			result := cleanMessage(tc.input)
+		t.Run(tc.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
 
-			if len(tc.expected) != len(result) {
-				t.Errorf("lengths don't match: '%v' vs '%v'", result, tc.expected)
+			if result := clientIP(r); result != tc.expected {
+				t.Errorf("Expected output [%s] does not match Actual output [%s]", tc.expected, result)
 			}
+		})
+	}
+}
+
+func TestPrepareChirpBody(t *testing.T) {
+	filter, err := moderation.NewWordList(append(moderation.DefaultRules(),
+		moderation.Rule{Word: "blorp", Action: moderation.ActionReject},
+	))
+	if err != nil {
+		t.Fatalf("NewWordList() error = %v", err)
+	}
 
-			for i := range tc.expected {
-				if tc.expected[i] != result[i] {
-					t.Errorf("Expected output [%s] does not match Actual output [%s]", tc.expected, result)
-				}
+	testCases := []struct {
+		input     string
+		maxLength int
+		expected  string
+		wantErr   bool
+	}{
+		{"hello kerfuffle", 140, "hello ****", false},
+		{"exactly ten", 11, "exactly ten", false},
+		{"one character too many", 21, "one character too many", true},
+		{"hello blorp", 140, "hello blorp", true},
+		{strings.Repeat("\U0001F600", 50), 140, strings.Repeat("\U0001F600", 50), false},
+		{"kerfuffle kerfuffle", 10, "**** ****", false},
+		{"read https://example.com/a/very/long/path/that/goes/on", 30, "read https://example.com/a/very/long/path/that/goes/on", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			result, err := prepareChirpBody(tc.input, tc.maxLength, filter)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("prepareChirpBody() error = %v, wantErr %v", err, tc.wantErr)
+				return
+			}
+			if result.Body != tc.expected {
+				t.Errorf("Expected output [%s] does not match Actual output [%s]", tc.expected, result.Body)
 			}
 		})
 	}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"strings"
@@ -19,3 +20,13 @@ func GetAPIKey(headers http.Header) (string, error) {
 
 	return splitAuth[1], nil
 }
+
+// ValidAPIKey reports whether key is one of keys. Every key is compared in
+// constant time so the result doesn't hint at how close a guess was.
+func ValidAPIKey(key string, keys []string) bool {
+	valid := 0
+	for _, k := range keys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), []byte(k))
+	}
+	return valid == 1
+}
@@ -51,3 +51,29 @@ func TestGetAPIKey(t *testing.T) {
 		})
 	}
 }
+
+func TestValidAPIKey(t *testing.T) {
+	keys := []string{"current_key", "previous_key"}
+
+	tests := []struct {
+		name string
+		key  string
+		keys []string
+		want bool
+	}{
+		{name: "Current key", key: "current_key", keys: keys, want: true},
+		{name: "Previous key", key: "previous_key", keys: keys, want: true},
+		{name: "Unknown key", key: "guessed_key", keys: keys, want: false},
+		{name: "Prefix of a key", key: "current", keys: keys, want: false},
+		{name: "Empty key", key: "", keys: keys, want: false},
+		{name: "No keys configured", key: "current_key", keys: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auth.ValidAPIKey(tt.key, tt.keys); got != tt.want {
+				t.Errorf("ValidAPIKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MakeChallengeToken signs the short-lived token handed out after a correct
+// password when the account still owes a second factor. It only proves the
+// first step and can't be used as an access token.
+func MakeChallengeToken(userID uuid.UUID, keys *Keyring, expiresIn time.Duration) (string, error) {
+	return keys.sign(jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeTwoFactor),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+}
+
+func ValidateChallengeToken(tokenString string, keys *Keyring) (uuid.UUID, error) {
+	claims := jwt.RegisteredClaims{}
+	_, err := keys.parse(tokenString, &claims)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if claims.Issuer != string(TokenTypeTwoFactor) {
+		return uuid.Nil, errors.New("invalid issuer")
+	}
+
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	return id, nil
+}
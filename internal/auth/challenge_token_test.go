@@ -0,0 +1,44 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+)
+
+func TestValidateChallengeToken(t *testing.T) {
+	keys := testKeyring(t)
+	userID := uuid.New()
+	validToken, _ := auth.MakeChallengeToken(userID, keys, time.Minute)
+	expiredToken, _ := auth.MakeChallengeToken(userID, keys, -time.Minute)
+	accessToken, _ := auth.MakeJWT(userID, auth.RoleUser, keys, time.Hour)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantErr     bool
+	}{
+		{name: "Valid token", tokenString: validToken},
+		{name: "Expired", tokenString: expiredToken, wantErr: true},
+		{name: "Access token", tokenString: accessToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := auth.ValidateChallengeToken(tt.tokenString, keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateChallengeToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && gotUserID != userID {
+				t.Errorf("ValidateChallengeToken() = %v, want %v", gotUserID, userID)
+			}
+		})
+	}
+
+	_, err := auth.ParseJWT(validToken, keys)
+	if err == nil {
+		t.Error("ParseJWT() accepted a challenge token")
+	}
+}
@@ -0,0 +1,54 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+)
+
+func TestValidateEmailToken(t *testing.T) {
+	keys := testKeyring(t)
+	otherKeys := testKeyring(t)
+	userID := uuid.New()
+	validToken, _ := auth.MakeEmailToken(userID, "walt@breakingbad.com", keys, time.Hour)
+	expiredToken, _ := auth.MakeEmailToken(userID, "walt@breakingbad.com", keys, -time.Hour)
+	accessToken, _ := auth.MakeJWT(userID, auth.RoleUser, keys, time.Hour)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		keyring     *auth.Keyring
+		wantErr     bool
+	}{
+		{name: "Valid token", tokenString: validToken, keyring: keys},
+		{name: "Wrong keyring", tokenString: validToken, keyring: otherKeys, wantErr: true},
+		{name: "Expired", tokenString: expiredToken, keyring: keys, wantErr: true},
+		{name: "Access token", tokenString: accessToken, keyring: keys, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, gotEmail, err := auth.ValidateEmailToken(tt.tokenString, tt.keyring)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateEmailToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotUserID != userID || gotEmail != "walt@breakingbad.com" {
+				t.Errorf("ValidateEmailToken() = %v, %q", gotUserID, gotEmail)
+			}
+		})
+	}
+}
+
+func TestParseJWTRejectsEmailToken(t *testing.T) {
+	keys := testKeyring(t)
+	token, _ := auth.MakeEmailToken(uuid.New(), "walt@breakingbad.com", keys, time.Hour)
+	_, err := auth.ParseJWT(token, keys)
+	if err == nil {
+		t.Error("ParseJWT() accepted an email verification token")
+	}
+}
@@ -1,23 +1,188 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+var (
+	ErrPasswordMismatch   = errors.New("password does not match hash")
+	ErrUnknownHashFormat  = errors.New("unknown password hash format")
+	errMalformedPHCString = errors.New("malformed argon2id hash")
+)
+
+// Hasher turns passwords into self-describing strings that record the
+// algorithm and its parameters, so hashes made with older settings can be
+// checked and then upgraded.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify checks password against a hash in this hasher's format and
+	// reports whether the hash was made with parameters other than the
+	// hasher's current ones.
+	Verify(password, hash string) (outdated bool, err error)
+	// Handles reports whether hash is in this hasher's format.
+	Handles(hash string) bool
+}
+
+// Argon2idParams follow the OWASP baseline for Argon2id.
+type Argon2idParams struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2id stores hashes as PHC strings:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+type Argon2id struct {
+	Params Argon2idParams
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.Params.SaltLength)
+	_, err := rand.Read(salt)
 	if err != nil {
 		return "", err
 	}
 
-	return string(hashedPassword), nil
+	key := argon2.IDKey([]byte(password), salt, a.Params.Iterations, a.Params.Memory, a.Params.Parallelism, a.Params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Params.Memory, a.Params.Iterations, a.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a Argon2id) Verify(password, hash string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	got := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return false, ErrPasswordMismatch
+	}
+
+	return params != a.Params, nil
+}
+
+func (a Argon2id) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
 }
 
-func CheckPasswordHash(password string, hash string) error {
+func parseArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idParams{}, nil, nil, errMalformedPHCString
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var params Argon2idParams
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2idParams{}, nil, nil, errMalformedPHCString
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return Argon2idParams{}, nil, nil, errMalformedPHCString
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idParams{}, nil, nil, errMalformedPHCString
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// Bcrypt checks the hashes stored before Argon2id became the default. Its
+// modular crypt strings ($2a$10$...) record the cost the same way PHC
+// strings record Argon2id's parameters.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(password, hash string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, ErrPasswordMismatch
+	}
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, err
+	}
+	return cost != b.Cost, nil
+}
+
+func (b Bcrypt) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// DefaultHasher makes every new hash. The other hashers in hashers are only
+// used to check existing ones.
+var DefaultHasher Hasher = Argon2id{Params: DefaultArgon2idParams}
+
+var hashers = []Hasher{
+	DefaultHasher,
+	Bcrypt{Cost: bcrypt.DefaultCost},
+}
+
+func HashPassword(password string) (string, error) {
+	return DefaultHasher.Hash(password)
+}
+
+// CheckPasswordHash verifies password against hash in any supported format.
+// needsRehash is true when the password is correct but the hash should be
+// replaced with one from HashPassword, either because it was made by an
+// older algorithm or with outdated parameters.
+func CheckPasswordHash(password string, hash string) (needsRehash bool, err error) {
+	for _, h := range hashers {
+		if !h.Handles(hash) {
+			continue
+		}
+
+		outdated, err := h.Verify(password, hash)
+		if err != nil {
+			return false, err
+		}
+		return outdated || !DefaultHasher.Handles(hash), nil
 	}
 
-	return nil
+	return false, ErrUnknownHashFormat
 }
@@ -1,6 +1,8 @@
 package auth_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/migomi3/internal/auth"
@@ -12,12 +14,23 @@ func TestCheckPasswordHash(t *testing.T) {
 	password2 := "anotherPassword456!"
 	hash1, _ := auth.HashPassword(password1)
 	hash2, _ := auth.HashPassword(password2)
+	bcryptHash, _ := auth.Bcrypt{Cost: 4}.Hash(password1)
+	weakHash, _ := auth.Argon2id{Params: auth.Argon2idParams{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}}.Hash(password1)
+	longPassword := strings.Repeat("a", 72) + "tail"
+	longHash, _ := auth.HashPassword(longPassword)
 
 	tests := []struct {
-		name     string
-		password string
-		hash     string
-		wantErr  bool
+		name            string
+		password        string
+		hash            string
+		wantErr         bool
+		wantNeedsRehash bool
 	}{
 		{
 			name:     "Correct password",
@@ -49,14 +62,76 @@ func TestCheckPasswordHash(t *testing.T) {
 			hash:     "invalidhash",
 			wantErr:  true,
 		},
+		{
+			name:     "Malformed argon2id hash",
+			password: password1,
+			hash:     "$argon2id$v=19$m=abc$salt$key",
+			wantErr:  true,
+		},
+		{
+			name:            "Legacy bcrypt hash",
+			password:        password1,
+			hash:            bcryptHash,
+			wantNeedsRehash: true,
+		},
+		{
+			name:     "Legacy bcrypt hash, wrong password",
+			password: "wrongPassword",
+			hash:     bcryptHash,
+			wantErr:  true,
+		},
+		{
+			name:            "Outdated argon2id parameters",
+			password:        password1,
+			hash:            weakHash,
+			wantNeedsRehash: true,
+		},
+		{
+			name:     "Bytes past 72 count",
+			password: strings.Repeat("a", 72) + "other",
+			hash:     longHash,
+			wantErr:  true,
+		},
+		{
+			name:     "Long password",
+			password: longPassword,
+			hash:     longHash,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := auth.CheckPasswordHash(tt.password, tt.hash)
+			needsRehash, err := auth.CheckPasswordHash(tt.password, tt.hash)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPasswordHash() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if needsRehash != tt.wantNeedsRehash {
+				t.Errorf("CheckPasswordHash() needsRehash = %v, want %v", needsRehash, tt.wantNeedsRehash)
+			}
 		})
 	}
 }
+
+func TestHashPasswordFormat(t *testing.T) {
+	hash, err := auth.HashPassword("correctPassword123!")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("unexpected PHC string %q", hash)
+	}
+
+	other, _ := auth.HashPassword("correctPassword123!")
+	if hash == other {
+		t.Error("hashes of the same password share a salt")
+	}
+}
+
+func TestCheckPasswordHashMismatchError(t *testing.T) {
+	hash, _ := auth.HashPassword("correctPassword123!")
+	_, err := auth.CheckPasswordHash("wrongPassword", hash)
+	if !errors.Is(err, auth.ErrPasswordMismatch) {
+		t.Errorf("CheckPasswordHash() error = %v, want ErrPasswordMismatch", err)
+	}
+}
@@ -12,47 +12,80 @@ import (
 type TokenType string
 
 const (
-	TokenTypeAccess TokenType = "chirpy-access"
+	TokenTypeAccess            TokenType = "chirpy-access"
+	TokenTypeEmailVerification TokenType = "chirpy-email-verification"
+	TokenTypeTwoFactor         TokenType = "chirpy-2fa-challenge"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
+// Claims are the parts of an access token handlers act on.
+type Claims struct {
+	UserID uuid.UUID
+	Role   Role
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Role Role `json:"role"`
+}
 
-	return token.SignedString([]byte(tokenSecret))
+// MakeJWT signs an access token with the keyring's active key. The kid
+// header tells verifiers, including other services reading the JWKS, which
+// public key to check it with.
+func MakeJWT(userID uuid.UUID, role Role, keys *Keyring, expiresIn time.Duration) (string, error) {
+	return keys.sign(accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Role: role,
+	})
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&claimsStruct,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
-	)
+func ValidateJWT(tokenString string, keys *Keyring) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, keys)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return claims.UserID, nil
+}
+
+// ParseJWT validates an access token and returns its claims. Tokens issued
+// before roles were added carry no role claim and are treated as RoleUser.
+func ParseJWT(tokenString string, keys *Keyring) (Claims, error) {
+	claimsStruct := accessClaims{}
+	token, err := keys.parse(tokenString, &claimsStruct)
+	if err != nil {
+		return Claims{}, err
+	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return Claims{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return Claims{}, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	role := claimsStruct.Role
+	if role == "" {
+		role = RoleUser
+	}
+	role, err = ParseRole(string(role))
+	if err != nil {
+		return Claims{}, err
 	}
-	return id, nil
+
+	return Claims{UserID: id, Role: role}, nil
 }
@@ -9,34 +9,36 @@ import (
 )
 
 func TestValidateJWT(t *testing.T) {
+	keys := testKeyring(t)
+	otherKeys := testKeyring(t)
 	userID := uuid.New()
-	validToken, _ := auth.MakeJWT(userID, "secret", time.Hour)
+	validToken, _ := auth.MakeJWT(userID, auth.RoleUser, keys, time.Hour)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keyring     *auth.Keyring
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keyring:     keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
+			keyring:     keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
 		{
-			name:        "Wrong secret",
+			name:        "Wrong keyring",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keyring:     otherKeys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
@@ -44,7 +46,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := auth.ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := auth.ValidateJWT(tt.tokenString, tt.keyring)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -55,3 +57,39 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJWTRole(t *testing.T) {
+	keys := testKeyring(t)
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		role auth.Role
+	}{
+		{name: "User", role: auth.RoleUser},
+		{name: "Moderator", role: auth.RoleModerator},
+		{name: "Admin", role: auth.RoleAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := auth.MakeJWT(userID, tt.role, keys, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			claims, err := auth.ParseJWT(token, keys)
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if claims.UserID != userID || claims.Role != tt.role {
+				t.Errorf("ParseJWT() = %+v, want user %v with role %v", claims, userID, tt.role)
+			}
+		})
+	}
+
+	token, _ := auth.MakeJWT(userID, "superuser", keys, time.Hour)
+	if _, err := auth.ParseJWT(token, keys); err == nil {
+		t.Error("ParseJWT() expected error for unknown role")
+	}
+}
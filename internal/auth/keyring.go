@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+
+	minRSABits = 2048
+)
+
+var (
+	ErrUnknownKey = errors.New("token signed with an unknown key")
+	errNoKeyID    = errors.New("token has no kid header")
+)
+
+// Key is one signing key. Keys loaded from a public key can only verify.
+type Key struct {
+	ID      string
+	Alg     string
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// NewKey wraps an Ed25519 or RSA key, private or public. Its ID is the RFC
+// 7638 thumbprint of the public key, so every service derives the same kid
+// without coordinating.
+func NewKey(k any) (*Key, error) {
+	key := &Key{}
+
+	switch k := k.(type) {
+	case ed25519.PrivateKey:
+		key.Alg, key.private, key.public = AlgEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Alg, key.public = AlgEdDSA, k
+	case *rsa.PrivateKey:
+		key.Alg, key.private, key.public = AlgRS256, k, k.Public()
+	case *rsa.PublicKey:
+		key.Alg, key.public = AlgRS256, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k)
+	}
+
+	if pub, ok := key.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+	}
+
+	thumbprint, err := json.Marshal(key.JWK().thumbprintMembers())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(thumbprint)
+	key.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return key, nil
+}
+
+// GenerateKey creates a new private key for alg.
+func GenerateKey(alg string) (*Key, error) {
+	switch alg {
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(priv)
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(priv)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// ParseKeyPEM reads a PKCS#8 private key or PKIX public key.
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var k any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(k)
+}
+
+// LoadKeyFile reads a key written by PrivatePEM or PublicPEM from path.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// PrivatePEM encodes the private key as PKCS#8.
+func (k *Key) PrivatePEM() ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("key has no private part")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicPEM encodes the public key as PKIX, the form retired keys are kept
+// in.
+func (k *Key) PublicPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.public)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Alg == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is a public key as published in a JWK Set (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Alg: k.Alg, Use: "sig"}
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+	return jwk
+}
+
+// thumbprintMembers returns the required members of the JWK in the
+// lexicographic order RFC 7638 hashes them in.
+func (j JWK) thumbprintMembers() any {
+	if j.Kty == "OKP" {
+		return struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+	return struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{j.E, j.Kty, j.N}
+}
+
+// Keyring signs with one active key and verifies with it or any retired
+// key. Retiring a key rather than dropping it lets tokens it signed run out
+// their lifetime after a rotation.
+type Keyring struct {
+	active *Key
+	keys   map[string]*Key
+	// legacySecret verifies HS256 tokens issued before keyrings existed.
+	legacySecret []byte
+}
+
+// NewKeyring signs with active, which must hold a private key, and
+// verifies with active and retired.
+func NewKeyring(active *Key, retired ...*Key) (*Keyring, error) {
+	if active == nil || active.private == nil {
+		return nil, errors.New("active key must be a private key")
+	}
+
+	kr := &Keyring{active: active, keys: map[string]*Key{active.ID: active}}
+	for _, k := range retired {
+		kr.keys[k.ID] = k
+	}
+	return kr, nil
+}
+
+// AcceptLegacySecret also accepts HS256 tokens without a kid, signed with
+// the shared secret used before tokens were signed by the keyring. It is
+// only meant for the changeover, until those tokens have expired.
+func (kr *Keyring) AcceptLegacySecret(secret string) {
+	kr.legacySecret = []byte(secret)
+}
+
+// ActiveKeyID returns the kid new tokens are signed with.
+func (kr *Keyring) ActiveKeyID() string {
+	return kr.active.ID
+}
+
+// JWKS returns every key that tokens may still be verified with, active
+// key first.
+func (kr *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{kr.active.JWK()}}
+	for _, id := range slices.Sorted(maps.Keys(kr.keys)) {
+		if id != kr.active.ID {
+			set.Keys = append(set.Keys, kr.keys[id].JWK())
+		}
+	}
+	return set
+}
+
+func (kr *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.active.method(), claims)
+	token.Header["kid"] = kr.active.ID
+	return token.SignedString(kr.active.private)
+}
+
+// keyFunc picks the verification key by kid and refuses a token whose alg
+// doesn't match that key, so a token can't choose how it is checked.
+func (kr *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if kr.legacySecret != nil && token.Method == jwt.SigningMethodHS256 {
+			return kr.legacySecret, nil
+		}
+		return nil, errNoKeyID
+	}
+
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Alg {
+		return nil, fmt.Errorf("token alg %s does not match key %s", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+func (kr *Keyring) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	methods := []string{AlgEdDSA, AlgRS256}
+	if kr.legacySecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	return jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		kr.keyFunc,
+		jwt.WithValidMethods(methods),
+	)
+}
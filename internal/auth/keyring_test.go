@@ -0,0 +1,240 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+)
+
+func testKeyring(t *testing.T) *auth.Keyring {
+	t.Helper()
+	key, err := auth.GenerateKey(auth.AlgEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	keys, err := auth.NewKeyring(key)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keys
+}
+
+func TestKeyringAlgorithms(t *testing.T) {
+	for _, alg := range []string{auth.AlgEdDSA, auth.AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := auth.GenerateKey(alg)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			keys, err := auth.NewKeyring(key)
+			if err != nil {
+				t.Fatalf("NewKeyring() error = %v", err)
+			}
+
+			userID := uuid.New()
+			token, err := auth.MakeJWT(userID, auth.RoleUser, keys, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() error = %v", err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != alg {
+				t.Errorf("header = %v, want kid %s alg %s", parsed.Header, key.ID, alg)
+			}
+
+			got, err := auth.ValidateJWT(token, keys)
+			if err != nil || got != userID {
+				t.Errorf("ValidateJWT() = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, _ := auth.GenerateKey(auth.AlgEdDSA)
+	newKey, _ := auth.GenerateKey(auth.AlgRS256)
+
+	before, _ := auth.NewKeyring(oldKey)
+	oldToken, _ := auth.MakeJWT(uuid.New(), auth.RoleUser, before, time.Hour)
+
+	// The old key is kept as public-only, the way a retired key is
+	// deployed.
+	pub, err := oldKey.PublicPEM()
+	if err != nil {
+		t.Fatalf("PublicPEM() error = %v", err)
+	}
+	retired, err := auth.ParseKeyPEM(pub)
+	if err != nil {
+		t.Fatalf("ParseKeyPEM() error = %v", err)
+	}
+	if retired.ID != oldKey.ID {
+		t.Errorf("retired kid %s differs from original %s", retired.ID, oldKey.ID)
+	}
+
+	after, err := auth.NewKeyring(newKey, retired)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	if after.ActiveKeyID() != newKey.ID {
+		t.Errorf("ActiveKeyID() = %s, want %s", after.ActiveKeyID(), newKey.ID)
+	}
+
+	_, err = auth.ValidateJWT(oldToken, after)
+	if err != nil {
+		t.Errorf("token from retired key rejected: %v", err)
+	}
+
+	withoutOld, _ := auth.NewKeyring(newKey)
+	_, err = auth.ValidateJWT(oldToken, withoutOld)
+	if err == nil {
+		t.Error("token from dropped key accepted")
+	}
+
+	_, err = auth.NewKeyring(retired)
+	if err == nil {
+		t.Error("NewKeyring() accepted a public key as the active key")
+	}
+}
+
+func TestKeyringRejectsForgedHeaders(t *testing.T) {
+	key, _ := auth.GenerateKey(auth.AlgEdDSA)
+	keys, _ := auth.NewKeyring(key)
+	pub, _ := key.PublicPEM()
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    string(auth.TokenTypeAccess),
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	// HS256 keyed with the public key, the classic algorithm confusion.
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = key.ID
+	hsToken, _ := hs.SignedString(pub)
+
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknown.Header["kid"] = "not-a-key"
+	unknownToken, _ := unknown.SignedString(otherPriv)
+
+	noKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	noKidToken, _ := noKid.SignedString(otherPriv)
+
+	// Signed by another key but claiming our kid.
+	stolenKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	stolenKid.Header["kid"] = key.ID
+	stolenKidToken, _ := stolenKid.SignedString(otherPriv)
+
+	for name, token := range map[string]string{
+		"HS256 with public key": hsToken,
+		"Unknown kid":           unknownToken,
+		"Missing kid":           noKidToken,
+		"Wrong signer":          stolenKidToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := auth.ValidateJWT(token, keys)
+			if err == nil {
+				t.Error("ValidateJWT() accepted forged token")
+			}
+		})
+	}
+}
+
+func TestKeyringLegacySecret(t *testing.T) {
+	userID := uuid.New()
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(auth.TokenTypeAccess),
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	legacyToken, err := legacy.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := testKeyring(t)
+	_, err = auth.ValidateJWT(legacyToken, keys)
+	if err == nil {
+		t.Error("legacy token accepted without AcceptLegacySecret")
+	}
+
+	keys.AcceptLegacySecret("wrong_secret")
+	_, err = auth.ValidateJWT(legacyToken, keys)
+	if err == nil {
+		t.Error("legacy token accepted with the wrong secret")
+	}
+
+	keys.AcceptLegacySecret("secret")
+	got, err := auth.ValidateJWT(legacyToken, keys)
+	if err != nil || got != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", got, err, userID)
+	}
+
+	// New tokens are still signed by the active key.
+	token, _ := auth.MakeJWT(userID, auth.RoleUser, keys, time.Hour)
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if parsed.Header["kid"] != keys.ActiveKeyID() {
+		t.Errorf("kid = %v, want %s", parsed.Header["kid"], keys.ActiveKeyID())
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	active, _ := auth.GenerateKey(auth.AlgEdDSA)
+	retired, _ := auth.GenerateKey(auth.AlgRS256)
+	keys, _ := auth.NewKeyring(active, retired)
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	ed := set.Keys[0]
+	if ed.Kid != active.ID || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != auth.AlgEdDSA || ed.Use != "sig" {
+		t.Errorf("unexpected Ed25519 JWK %+v", ed)
+	}
+	if x, err := base64.RawURLEncoding.DecodeString(ed.X); err != nil || len(x) != ed25519.PublicKeySize {
+		t.Errorf("invalid x %q", ed.X)
+	}
+
+	rsaJWK := set.Keys[1]
+	if rsaJWK.Kid != retired.ID || rsaJWK.Kty != "RSA" || rsaJWK.E != "AQAB" || rsaJWK.N == "" {
+		t.Errorf("unexpected RSA JWK %+v", rsaJWK)
+	}
+
+	for _, k := range set.Keys {
+		if strings.ContainsAny(k.Kid, "+/=") {
+			t.Errorf("kid %q is not base64url", k.Kid)
+		}
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	key, _ := auth.GenerateKey(auth.AlgEdDSA)
+	priv, err := key.PrivatePEM()
+	if err != nil {
+		t.Fatalf("PrivatePEM() error = %v", err)
+	}
+
+	parsed, err := auth.ParseKeyPEM(priv)
+	if err != nil {
+		t.Fatalf("ParseKeyPEM() error = %v", err)
+	}
+	if parsed.ID != key.ID || parsed.Alg != auth.AlgEdDSA {
+		t.Errorf("ParseKeyPEM() = %s %s, want %s %s", parsed.ID, parsed.Alg, key.ID, auth.AlgEdDSA)
+	}
+
+	_, err = auth.ParseKeyPEM([]byte("not pem"))
+	if err == nil {
+		t.Error("ParseKeyPEM() expected error")
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MakeRecoveryCodes returns n one-time codes formatted as two groups of five
+// characters, e.g. "k3jd9-2mfqa". Each carries 50 random bits.
+func MakeRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for range n {
+		b := make([]byte, 7)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, s[:5]+"-"+s[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a code for storage, ignoring case, spaces and
+// dashes so the code can be typed back however the user wrote it down.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
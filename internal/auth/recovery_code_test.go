@@ -0,0 +1,42 @@
+package auth_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/migomi3/internal/auth"
+)
+
+func TestMakeRecoveryCodes(t *testing.T) {
+	codes, err := auth.MakeRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != auth.RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), auth.RecoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if !format.MatchString(c) {
+			t.Errorf("code %q has invalid format", c)
+		}
+		if seen[c] {
+			t.Errorf("duplicate code %q", c)
+		}
+		seen[c] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := auth.HashRecoveryCode("k3jd9-2mfqa")
+	for _, variant := range []string{"K3JD9-2MFQA", "k3jd92mfqa", " k3jd9 2mfqa "} {
+		if got := auth.HashRecoveryCode(variant); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from canonical form", variant)
+		}
+	}
+	if auth.HashRecoveryCode("k3jd9-2mfqb") == want {
+		t.Error("different codes produced the same hash")
+	}
+}
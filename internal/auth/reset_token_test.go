@@ -0,0 +1,23 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/migomi3/internal/auth"
+)
+
+func TestHashToken(t *testing.T) {
+	a := auth.HashToken("token")
+	if len(a) != 64 {
+		t.Errorf("hash has invalid length: %d", len(a))
+	}
+	if a != auth.HashToken("token") {
+		t.Error("hash is not deterministic")
+	}
+	if a == auth.HashToken("other") {
+		t.Error("different tokens produced the same hash")
+	}
+	if a == "token" {
+		t.Error("hash equals the token")
+	}
+}
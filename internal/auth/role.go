@@ -0,0 +1,36 @@
+package auth
+
+import "fmt"
+
+// Role is a user's level of access. Each role includes the access of the
+// roles below it.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRank[r]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// Allows reports whether r grants at least the access of required.
+func (r Role) Allows(required Role) bool {
+	have, ok := roleRank[r]
+	if !ok {
+		return false
+	}
+	return have >= roleRank[required]
+}
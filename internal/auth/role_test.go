@@ -0,0 +1,33 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/migomi3/internal/auth"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     auth.Role
+		required auth.Role
+		want     bool
+	}{
+		{auth.RoleUser, auth.RoleUser, true},
+		{auth.RoleUser, auth.RoleModerator, false},
+		{auth.RoleUser, auth.RoleAdmin, false},
+		{auth.RoleModerator, auth.RoleUser, true},
+		{auth.RoleModerator, auth.RoleModerator, true},
+		{auth.RoleModerator, auth.RoleAdmin, false},
+		{auth.RoleAdmin, auth.RoleModerator, true},
+		{auth.RoleAdmin, auth.RoleAdmin, true},
+		{"superuser", auth.RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role)+"/"+string(tt.required), func(t *testing.T) {
+			if got := tt.role.Allows(tt.required); got != tt.want {
+				t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+			}
+		})
+	}
+}
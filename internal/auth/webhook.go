@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookTimestampHeader = "Polka-Timestamp"
+	WebhookSignatureHeader = "Polka-Signature"
+
+	// WebhookTolerance bounds how far a delivery's timestamp may drift from
+	// our clock before it is treated as a replay.
+	WebhookTolerance = 5 * time.Minute
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func SignWebhook(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature headers against every key in
+// keys, so old and new secrets can both be accepted while rotating.
+func VerifyWebhookSignature(headers http.Header, body []byte, keys []string, now time.Time) error {
+	timestampHeader := headers.Get(WebhookTimestampHeader)
+	if timestampHeader == "" {
+		return errors.New("no webhook timestamp header found")
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed webhook timestamp: %w", err)
+	}
+
+	drift := now.Sub(time.Unix(timestamp, 0))
+	if drift > WebhookTolerance || drift < -WebhookTolerance {
+		return errors.New("webhook timestamp outside tolerance window")
+	}
+
+	signatureHeader := headers.Get(WebhookSignatureHeader)
+	if signatureHeader == "" {
+		return errors.New("no webhook signature header found")
+	}
+
+	for _, candidate := range strings.Split(signatureHeader, ",") {
+		signature, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(candidate), "v1="))
+		if err != nil {
+			continue
+		}
+
+		for _, key := range keys {
+			expected, _ := hex.DecodeString(SignWebhook(key, timestamp, body))
+			if hmac.Equal(signature, expected) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("webhook signature mismatch")
+}
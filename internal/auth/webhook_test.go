@@ -0,0 +1,101 @@
+package auth_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/auth"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	now := time.Now()
+	ts := now.Unix()
+	tsHeader := strconv.FormatInt(ts, 10)
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		body    []byte
+		keys    []string
+		wantErr bool
+	}{
+		{
+			name: "Valid signature",
+			headers: http.Header{
+				"Polka-Timestamp": []string{tsHeader},
+				"Polka-Signature": []string{"v1=" + auth.SignWebhook("current", ts, body)},
+			},
+			body:    body,
+			keys:    []string{"current"},
+			wantErr: false,
+		},
+		{
+			name: "Signed with retiring key",
+			headers: http.Header{
+				"Polka-Timestamp": []string{tsHeader},
+				"Polka-Signature": []string{"v1=" + auth.SignWebhook("previous", ts, body)},
+			},
+			body:    body,
+			keys:    []string{"current", "previous"},
+			wantErr: false,
+		},
+		{
+			name: "Unknown key",
+			headers: http.Header{
+				"Polka-Timestamp": []string{tsHeader},
+				"Polka-Signature": []string{"v1=" + auth.SignWebhook("other", ts, body)},
+			},
+			body:    body,
+			keys:    []string{"current"},
+			wantErr: true,
+		},
+		{
+			name: "Tampered body",
+			headers: http.Header{
+				"Polka-Timestamp": []string{tsHeader},
+				"Polka-Signature": []string{"v1=" + auth.SignWebhook("current", ts, body)},
+			},
+			body:    []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			keys:    []string{"current"},
+			wantErr: true,
+		},
+		{
+			name: "Stale timestamp",
+			headers: http.Header{
+				"Polka-Timestamp": []string{strconv.FormatInt(ts-3600, 10)},
+				"Polka-Signature": []string{"v1=" + auth.SignWebhook("current", ts-3600, body)},
+			},
+			body:    body,
+			keys:    []string{"current"},
+			wantErr: true,
+		},
+		{
+			name: "Missing signature",
+			headers: http.Header{
+				"Polka-Timestamp": []string{tsHeader},
+			},
+			body:    body,
+			keys:    []string{"current"},
+			wantErr: true,
+		},
+		{
+			name:    "Missing timestamp",
+			headers: http.Header{},
+			body:    body,
+			keys:    []string{"current"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.VerifyWebhookSignature(tt.headers, tt.body, tt.keys, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyWebhookSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package charcount
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// URLWeight is how many characters a link counts for, whatever its real
+// length, so shortened and long links cost the same.
+const URLWeight = 23
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// Count returns the length of body in user-perceived characters (grapheme
+// clusters), with every URL counted as URLWeight. An emoji built from
+// several code points, such as a flag or a family, counts as one.
+func Count(body string) int {
+	n := 0
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(body, -1) {
+		end := loc[0] + len(trimURL(body[loc[0]:loc[1]]))
+		n += uniseg.GraphemeClusterCount(body[last:loc[0]])
+		n += URLWeight
+		last = end
+	}
+	n += uniseg.GraphemeClusterCount(body[last:])
+	return n
+}
+
+// trimURL drops trailing punctuation that ends the sentence rather than the
+// link, keeping a closing parenthesis when the URL contains the opening one.
+func trimURL(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'*", last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
@@ -0,0 +1,42 @@
+package charcount_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/migomi3/internal/charcount"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "Empty", input: "", want: 0},
+		{name: "ASCII", input: "hello world", want: 11},
+		{name: "Accented", input: "café", want: 4},
+		{name: "Combining accent", input: "cafe\u0301", want: 4},
+		{name: "Emoji", input: strings.Repeat("😀", 50), want: 50},
+		{name: "Flag", input: "🇳🇿", want: 1},
+		{name: "Family", input: "\U0001F468\u200d\U0001F469\u200d\U0001F467", want: 1},
+		{name: "Skin tone", input: "\U0001F44D\U0001F3FD", want: 1},
+		{name: "CJK", input: "你好", want: 2},
+		{name: "URL", input: "https://example.com/a/very/long/path?with=query", want: charcount.URLWeight},
+		{name: "Short URL", input: "http://x.co", want: charcount.URLWeight},
+		{name: "URL in text", input: "see https://example.com ok", want: 4 + charcount.URLWeight + 3},
+		{name: "URL before period", input: "https://example.com.", want: charcount.URLWeight + 1},
+		{name: "URL in parentheses", input: "(https://example.com)", want: 1 + charcount.URLWeight + 1},
+		{name: "URL with parentheses", input: "https://en.wikipedia.org/wiki/Go_(language)", want: charcount.URLWeight},
+		{name: "Two URLs", input: "https://a.com https://b.com", want: 2*charcount.URLWeight + 1},
+		{name: "No scheme", input: "example.com", want: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := charcount.Count(tt.input); got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
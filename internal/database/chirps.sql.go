@@ -7,10 +7,44 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const adjustLikeCount = `-- name: AdjustLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + $1::int
+WHERE id = $2
+`
+
+type AdjustLikeCountParams struct {
+	Delta int32
+	ID    uuid.UUID
+}
+
+func (q *Queries) AdjustLikeCount(ctx context.Context, arg AdjustLikeCountParams) error {
+	_, err := q.db.ExecContext(ctx, adjustLikeCount, arg.Delta, arg.ID)
+	return err
+}
+
+const adjustRechirpCount = `-- name: AdjustRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count + $1::int
+WHERE id = $2
+`
+
+type AdjustRechirpCountParams struct {
+	Delta int32
+	ID    uuid.UUID
+}
+
+func (q *Queries) AdjustRechirpCount(ctx context.Context, arg AdjustRechirpCountParams) error {
+	_, err := q.db.ExecContext(ctx, adjustRechirpCount, arg.Delta, arg.ID)
+	return err
+}
+
 const clearChirps = `-- name: ClearChirps :exec
 DELETE FROM chirps
 `
@@ -21,18 +55,20 @@ func (q *Queries) ClearChirps(ctx context.Context) error {
 }
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), Now(), Now(), $1, $2)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, entities)
+VALUES (gen_random_uuid(), Now(), Now(), $1, $2, $3, $4)
+RETURNING id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	Entities  json.RawMessage
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.InReplyTo, arg.Entities)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -40,10 +76,30 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.Entities,
+		&i.SearchDocument,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
+const decrementReplyCount = `-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count - 1
+WHERE id = $1 AND reply_count > 0
+`
+
+func (q *Queries) DecrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementReplyCount, id)
+	return err
+}
+
 const deleteChirp = `-- name: DeleteChirp :exec
 DELETE FROM chirps
 WHERE id = $1
@@ -54,14 +110,115 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirp = `-- name: GetChirp :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
 FROM chirps
-ORDER BY created_at ASC
+WHERE id = $1
+`
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.Entities,
+		&i.SearchDocument,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM chirps
+WHERE id = $1
+FOR UPDATE
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.Entities,
+		&i.SearchDocument,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = Now()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const incrementReplyCount = `-- name: IncrementReplyCount :execrows
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) IncrementReplyCount(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, incrementReplyCount, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listChirpAncestors = `-- name: ListChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT c.*, 1 AS depth
+    FROM chirps c
+    WHERE c.id = (SELECT in_reply_to FROM chirps WHERE id = $1::uuid)
+    UNION ALL
+    SELECT p.*, a.depth + 1
+    FROM chirps p
+    JOIN ancestors a ON p.id = a.in_reply_to
+    WHERE a.depth < $2::int
+)
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM ancestors
+WHERE user_id = $3::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = ancestors.user_id AND users.status = 'shadowbanned'
+)
+ORDER BY depth DESC
+`
+
+type ListChirpAncestorsParams struct {
+	ChirpID  uuid.UUID
+	MaxDepth int32
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) ListChirpAncestors(ctx context.Context, arg ListChirpAncestorsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpAncestors, arg.ChirpID, arg.MaxDepth, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +232,15 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -89,34 +255,271 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id
+const listChirpDescendants = `-- name: ListChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT c.*, 1 AS depth
+    FROM chirps c
+    WHERE c.in_reply_to = $1::uuid
+      AND (c.user_id = $2::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = c.user_id AND users.status = 'shadowbanned'
+      ))
+    UNION ALL
+    SELECT r.*, d.depth + 1
+    FROM chirps r
+    JOIN descendants d ON r.in_reply_to = d.id
+    WHERE d.depth < $3::int
+      AND (r.user_id = $2::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = r.user_id AND users.status = 'shadowbanned'
+      ))
+)
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM descendants
+ORDER BY depth, created_at, id
+LIMIT $4
+`
+
+type ListChirpDescendantsParams struct {
+	ChirpID    uuid.UUID
+	ViewerID   uuid.NullUUID
+	MaxDepth   int32
+	MaxReplies int32
+}
+
+// Hidden replies are filtered inside the recursion, so their whole branch
+// is pruned: nothing comes back without its parent, and nothing the viewer
+// can't see counts against max_replies.
+func (q *Queries) ListChirpDescendants(ctx context.Context, arg ListChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpDescendants, arg.ChirpID, arg.ViewerID, arg.MaxDepth, arg.MaxReplies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
 FROM chirps
-WHERE id = $1
+WHERE deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) > ($1::timestamp, $2::uuid)
+  AND (user_id = $3::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
-	)
-	return i, err
+type ListChirpsAscParams struct {
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	ViewerID       uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc, arg.AfterCreatedAt, arg.AfterID, arg.ViewerID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < ($1::timestamp, $2::uuid)
+  AND (user_id = $3::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	BeforeCreatedAt time.Time
+	BeforeID        uuid.UUID
+	ViewerID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, arg.BeforeCreatedAt, arg.BeforeID, arg.ViewerID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsForExport = `-- name: ListChirpsForExport :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM chirps
+WHERE user_id = $1
+  AND created_at >= $2::timestamp
+  AND deleted_at IS NULL
+  AND (created_at, id) > ($3::timestamp, $4::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $5
+`
+
+type ListChirpsForExportParams struct {
+	UserID         uuid.UUID
+	Since          time.Time
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsForExport(ctx context.Context, arg ListChirpsForExportParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsForExport, arg.UserID, arg.Since, arg.AfterCreatedAt, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getChirpsFromUser = `-- name: GetChirpsFromUser :many
-SELECT id, created_at, updated_at, body, user_id
+const listChirpsFromUserAsc = `-- name: ListChirpsFromUserAsc :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
 FROM chirps
 WHERE user_id = $1
-ORDER BY created_at ASC
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) > ($2::timestamp, $3::uuid)
+  AND (user_id = $4::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at ASC, id ASC
+LIMIT $5
 `
 
-func (q *Queries) GetChirpsFromUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsFromUser, userID)
+type ListChirpsFromUserAscParams struct {
+	UserID         uuid.UUID
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	ViewerID       uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsFromUserAsc(ctx context.Context, arg ListChirpsFromUserAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsFromUserAsc, arg.UserID, arg.AfterCreatedAt, arg.AfterID, arg.ViewerID, arg.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +533,15 @@ func (q *Queries) GetChirpsFromUser(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -143,3 +555,189 @@ func (q *Queries) GetChirpsFromUser(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const listChirpsFromUserDesc = `-- name: ListChirpsFromUserDesc :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM chirps
+WHERE user_id = $1
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < ($2::timestamp, $3::uuid)
+  AND (user_id = $4::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListChirpsFromUserDescParams struct {
+	UserID          uuid.UUID
+	BeforeCreatedAt time.Time
+	BeforeID        uuid.UUID
+	ViewerID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListChirpsFromUserDesc(ctx context.Context, arg ListChirpsFromUserDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsFromUserDesc, arg.UserID, arg.BeforeCreatedAt, arg.BeforeID, arg.ViewerID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM chirps
+WHERE user_id IN (
+    SELECT followee_id
+    FROM follows
+    WHERE follower_id = $1
+)
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < ($2::timestamp, $3::uuid)
+  AND (user_id = $4::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListTimelineParams struct {
+	FollowerID      uuid.UUID
+	BeforeCreatedAt time.Time
+	BeforeID        uuid.UUID
+	ViewerID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline, arg.FollowerID, arg.BeforeCreatedAt, arg.BeforeID, arg.ViewerID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Entities,
+			&i.SearchDocument,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :one
+UPDATE chirps
+SET body = '', entities = '{}', updated_at = Now(), deleted_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+`
+
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, tombstoneChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.Entities,
+		&i.SearchDocument,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, entities = $3, updated_at = Now(), edited_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID       uuid.UUID
+	Body     string
+	Entities json.RawMessage
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body, arg.Entities)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.Entities,
+		&i.SearchDocument,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -6,17 +6,103 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuthEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Event     string
+	UserID    uuid.NullUUID
+	Email     string
+	IpAddress string
+	UserAgent string
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string `json:"body"`
-	UserID    uuid.UUID  `json:"user_id"`
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	EditedAt       sql.NullTime
+	InReplyTo      uuid.NullUUID
+	ReplyCount     int32
+	DeletedAt      sql.NullTime
+	LikeCount      int32
+	RechirpCount   int32
+	Entities       json.RawMessage
+	SearchDocument interface{}
+	HiddenAt       sql.NullTime
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID
+	Words     []string
+	CreatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID        uuid.UUID
+	Tag            string
+	ChirpCreatedAt time.Time
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type ModerationWord struct {
+	Word      string
+	Action    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Rechirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
@@ -26,13 +112,69 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	RotatedAt sql.NullTime
+	UserAgent string
+	IpAddress string
+}
+
+type Report struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	Status     string
+	CreatedAt  time.Time
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	EndedAt            sql.NullTime
+}
+
+type TotpCredential struct {
+	UserID       uuid.UUID
+	Secret       string
+	CreatedAt    time.Time
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Handle          sql.NullString
+	Status          string
+	SuspendedUntil  sql.NullTime
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+}
+
+type Warning struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	ChirpID     uuid.NullUUID
+	ModeratorID uuid.NullUUID
+	Reason      string
+	CreatedAt   time.Time
+}
+
+type WebhookEvent struct {
+	ID         string
+	Event      string
+	ReceivedAt time.Time
 }
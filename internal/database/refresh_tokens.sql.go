@@ -22,19 +22,22 @@ func (q *Queries) ClearTokens(ctx context.Context) error {
 }
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
-VALUES ($1, Now(), Now(), $2, $3, NULL)
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip_address)
+VALUES ($1, Now(), Now(), $2, $3, NULL, $4, $5, $6)
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, user_agent, ip_address
 `
 
 type CreateRefreshTokenParams struct {
 	Token     string
 	UserID    uuid.UUID
 	ExpiresAt time.Time
+	FamilyID  uuid.UUID
+	UserAgent string
+	IpAddress string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt, arg.FamilyID, arg.UserAgent, arg.IpAddress)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -43,12 +46,16 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, user_agent, ip_address
 FROM refresh_tokens
 WHERE token = $1
 `
@@ -63,19 +70,115 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
 
-const revokeToken = `-- name: RevokeToken :one
+const listSessions = `-- name: ListSessions :many
+SELECT family_id,
+    (SELECT min(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS created_at,
+    created_at AS last_used_at,
+    expires_at,
+    user_agent,
+    ip_address
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > Now()
+ORDER BY refresh_tokens.created_at DESC
+`
+
+type ListSessionsRow struct {
+	FamilyID   uuid.UUID
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IpAddress  string
+}
+
+func (q *Queries) ListSessions(ctx context.Context, userID uuid.UUID) ([]ListSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.IpAddress,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllSessions = `-- name: RevokeAllSessions :exec
 UPDATE refresh_tokens
 SET revoked_at = Now(), updated_at = Now()
-WHERE token = $1
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllSessions, userID)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = Now(), updated_at = Now()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeTokenFamily = `-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = Now(), updated_at = Now()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeTokenFamily, familyID)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET rotated_at = Now(), updated_at = Now()
+WHERE token = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, user_agent, ip_address
 `
 
-func (q *Queries) RevokeToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, revokeToken, token)
+func (q *Queries) RotateRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, token)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -84,6 +187,10 @@ func (q *Queries) RevokeToken(ctx context.Context, token string) (RefreshToken,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
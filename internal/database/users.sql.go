@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -20,10 +21,41 @@ func (q *Queries) ClearUsers(ctx context.Context) error {
 	return err
 }
 
+const confirmEmailChange = `-- name: ConfirmEmailChange :one
+UPDATE users
+SET email = pending_email, pending_email = NULL, email_verified_at = Now(), updated_at = Now()
+WHERE id = $1 AND pending_email = $2
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+`
+
+type ConfirmEmailChangeParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+func (q *Queries) ConfirmEmailChange(ctx context.Context, arg ConfirmEmailChangeParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmEmailChange, arg.ID, arg.PendingEmail)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (gen_random_uuid(), Now(), Now(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
 `
 
 type CreateUserParams struct {
@@ -40,13 +72,18 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
 FROM users
 WHERE email = $1
 `
@@ -60,13 +97,43 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+FROM users
+WHERE lower(handle) = lower($1::text)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
 const getUserFromID = `-- name: GetUserFromID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
 FROM users
 WHERE id = $1
 `
@@ -80,26 +147,175 @@ func (q *Queries) GetUserFromID(ctx context.Context, id uuid.UUID) (User, error)
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
-const updateLoginInfo = `-- name: UpdateLoginInfo :one
+const markEmailVerified = `-- name: MarkEmailVerified :one
 UPDATE users
-SET email = $1, hashed_password = $2
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET email_verified_at = Now(), updated_at = Now()
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
 `
 
-type UpdateLoginInfoParams struct {
-	Email          string
-	HashedPassword string
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const rehashPassword = `-- name: RehashPassword :execrows
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2 AND hashed_password = $3
+`
+
+type RehashPasswordParams struct {
+	NewHash string
+	ID      uuid.UUID
+	OldHash string
+}
+
+func (q *Queries) RehashPassword(ctx context.Context, arg RehashPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rehashPassword, arg.NewHash, arg.ID, arg.OldHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :one
+UPDATE users
+SET pending_email = $2, updated_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+`
+
+type SetPendingEmailParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setPendingEmail, arg.ID, arg.PendingEmail)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const setUserHandle = `-- name: SetUserHandle :one
+UPDATE users
+SET handle = $2, updated_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+`
+
+type SetUserHandleParams struct {
+	ID     uuid.UUID
+	Handle sql.NullString
+}
+
+func (q *Queries) SetUserHandle(ctx context.Context, arg SetUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserHandle, arg.ID, arg.Handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
+const setUserStatus = `-- name: SetUserStatus :one
+UPDATE users
+SET status = $2, suspended_until = $3, updated_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
+`
+
+type SetUserStatusParams struct {
 	ID             uuid.UUID
+	Status         string
+	SuspendedUntil sql.NullTime
 }
 
-func (q *Queries) UpdateLoginInfo(ctx context.Context, arg UpdateLoginInfoParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateLoginInfo, arg.Email, arg.HashedPassword, arg.ID)
+func (q *Queries) SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserStatus, arg.ID, arg.Status, arg.SuspendedUntil)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -107,20 +323,30 @@ func (q *Queries) UpdateLoginInfo(ctx context.Context, arg UpdateLoginInfoParams
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
-const upgradeUser = `-- name: UpgradeUser :one
+const updatePassword = `-- name: UpdatePassword :one
 UPDATE users
-SET is_chirpy_red = true
+SET hashed_password = $2, updated_at = Now()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, status, suspended_until, role, email_verified_at, pending_email
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUser, id)
+type UpdatePasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -128,7 +354,12 @@ func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Status,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
@@ -0,0 +1,37 @@
+// Package emailaddr validates and normalizes account email addresses.
+package emailaddr
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// MaxLength is the longest address that fits in an SMTP forward path.
+const MaxLength = 254
+
+var ErrInvalid = errors.New("invalid email address")
+
+// Normalize checks that s is a bare addr-spec (no display name or angle
+// brackets) with a dotted domain and returns it trimmed and lower-cased.
+// Addresses are treated as case-insensitive throughout, so two accounts
+// can't differ only by case.
+func Normalize(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > MaxLength {
+		return "", ErrInvalid
+	}
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", ErrInvalid
+	}
+
+	at := strings.LastIndexByte(s, '@')
+	domain := s[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", ErrInvalid
+	}
+
+	return strings.ToLower(s), nil
+}
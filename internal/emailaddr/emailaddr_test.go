@@ -0,0 +1,41 @@
+package emailaddr_test
+
+import (
+	"testing"
+
+	"github.com/migomi3/internal/emailaddr"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Plain", input: "walt@breakingbad.com", want: "walt@breakingbad.com"},
+		{name: "Mixed case", input: "Walt@BreakingBad.COM", want: "walt@breakingbad.com"},
+		{name: "Surrounding space", input: "  saul@bettercall.com\n", want: "saul@bettercall.com"},
+		{name: "Plus tag", input: "jesse+chirpy@example.co.uk", want: "jesse+chirpy@example.co.uk"},
+		{name: "Empty", input: "", wantErr: true},
+		{name: "No at", input: "walt.breakingbad.com", wantErr: true},
+		{name: "No domain dot", input: "walt@localhost", wantErr: true},
+		{name: "Trailing dot", input: "walt@example.com.", wantErr: true},
+		{name: "Display name", input: "Walt <walt@breakingbad.com>", wantErr: true},
+		{name: "Angle brackets", input: "<walt@breakingbad.com>", wantErr: true},
+		{name: "Two addresses", input: "a@example.com, b@example.com", wantErr: true},
+		{name: "Space inside", input: "wa lt@example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := emailaddr.Normalize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
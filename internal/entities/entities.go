@@ -0,0 +1,91 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Mention refers to a user by their public handle, written "@handle".
+// UserID is filled in once the handle is resolved.
+type Mention struct {
+	Handle string    `json:"handle"`
+	UserID uuid.UUID `json:"user_id"`
+	Start  int       `json:"start"`
+	End    int       `json:"end"`
+}
+
+// Entities are the links found in a chirp body. Offsets are in Unicode code
+// points with End exclusive, so clients can slice the body without
+// re-parsing it.
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+}
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])(#[\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])(@[A-Za-z0-9_]+)`)
+	handlePattern  = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+)
+
+// Parse extracts hashtags and mentions from a chirp body. Hashtags must
+// contain at least one letter, so "#1" is left alone.
+func Parse(body string) Entities {
+	e := Entities{
+		Hashtags: []Hashtag{},
+		Mentions: []Mention{},
+	}
+
+	for _, m := range hashtagPattern.FindAllStringSubmatchIndex(body, -1) {
+		text := body[m[2]:m[3]]
+		if !strings.ContainsFunc(text, unicode.IsLetter) {
+			continue
+		}
+		start := utf8.RuneCountInString(body[:m[2]])
+		e.Hashtags = append(e.Hashtags, Hashtag{
+			Tag:   NormalizeTag(text[1:]),
+			Start: start,
+			End:   start + utf8.RuneCountInString(text),
+		})
+	}
+
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		text := body[m[2]:m[3]]
+		// "@walt@example.com" is an address, not a mention of walt.
+		if m[3] < len(body) && body[m[3]] == '@' {
+			continue
+		}
+		if !ValidHandle(text[1:]) {
+			continue
+		}
+		start := utf8.RuneCountInString(body[:m[2]])
+		e.Mentions = append(e.Mentions, Mention{
+			Handle: text[1:],
+			Start:  start,
+			End:    start + utf8.RuneCountInString(text),
+		})
+	}
+
+	return e
+}
+
+// ValidHandle reports whether handle can be taken by a user: 3 to 30
+// letters, digits or underscores. Handles are matched case-insensitively.
+func ValidHandle(handle string) bool {
+	return handlePattern.MatchString(handle)
+}
+
+// NormalizeTag returns the form a hashtag is stored and looked up under.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
@@ -0,0 +1,116 @@
+package entities_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/migomi3/internal/entities"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantHashtags []entities.Hashtag
+		wantMentions []entities.Mention
+	}{
+		{
+			name:         "No entities",
+			body:         "just a chirp",
+			wantHashtags: []entities.Hashtag{},
+			wantMentions: []entities.Mention{},
+		},
+		{
+			name: "Hashtag is lowercased",
+			body: "loving #GoLang today",
+			wantHashtags: []entities.Hashtag{
+				{Tag: "golang", Start: 7, End: 14},
+			},
+			wantMentions: []entities.Mention{},
+		},
+		{
+			name: "Offsets count code points",
+			body: "🐦🐦 #chirp",
+			wantHashtags: []entities.Hashtag{
+				{Tag: "chirp", Start: 3, End: 9},
+			},
+			wantMentions: []entities.Mention{},
+		},
+		{
+			name:         "Numeric tags and anchors are ignored",
+			body:         "issue #1 and page.html#top",
+			wantHashtags: []entities.Hashtag{},
+			wantMentions: []entities.Mention{},
+		},
+		{
+			name:         "Mention with trailing punctuation",
+			body:         "hi @Walt_White.",
+			wantHashtags: []entities.Hashtag{},
+			wantMentions: []entities.Mention{
+				{Handle: "Walt_White", Start: 3, End: 14},
+			},
+		},
+		{
+			name:         "Email is not a mention",
+			body:         "mail walt@breakingbad.com or @walt@breakingbad.com",
+			wantHashtags: []entities.Hashtag{},
+			wantMentions: []entities.Mention{},
+		},
+		{
+			name:         "Handle length limits",
+			body:         "@ab @abc @" + strings.Repeat("a", 31),
+			wantHashtags: []entities.Hashtag{},
+			wantMentions: []entities.Mention{
+				{Handle: "abc", Start: 4, End: 8},
+			},
+		},
+		{
+			name: "Both",
+			body: "@saul #law #Law",
+			wantHashtags: []entities.Hashtag{
+				{Tag: "law", Start: 6, End: 10},
+				{Tag: "law", Start: 11, End: 15},
+			},
+			wantMentions: []entities.Mention{
+				{Handle: "saul", Start: 0, End: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := entities.Parse(tt.body)
+			if !reflect.DeepEqual(got.Hashtags, tt.wantHashtags) {
+				t.Errorf("Parse() hashtags = %+v, want %+v", got.Hashtags, tt.wantHashtags)
+			}
+			if !reflect.DeepEqual(got.Mentions, tt.wantMentions) {
+				t.Errorf("Parse() mentions = %+v, want %+v", got.Mentions, tt.wantMentions)
+			}
+		})
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   bool
+	}{
+		{handle: "walt", want: true},
+		{handle: "Walt_White_99", want: true},
+		{handle: "ab", want: false},
+		{handle: strings.Repeat("a", 30), want: true},
+		{handle: strings.Repeat("a", 31), want: false},
+		{handle: "walt.white", want: false},
+		{handle: "walt@breakingbad.com", want: false},
+		{handle: "wält", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			if got := entities.ValidHandle(tt.handle); got != tt.want {
+				t.Errorf("ValidHandle(%q) = %v, want %v", tt.handle, got, tt.want)
+			}
+		})
+	}
+}
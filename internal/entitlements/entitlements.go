@@ -0,0 +1,83 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Plan string
+
+const (
+	PlanFree Plan = "free"
+	PlanRed  Plan = "chirpy_red"
+)
+
+// Entitlements are the limits and features granted by a plan.
+type Entitlements struct {
+	MaxChirpLength    int  `json:"max_chirp_length"`
+	CanEditChirps     bool `json:"can_edit_chirps"`
+	RequestsPerMinute int  `json:"requests_per_minute"`
+	// ExportHistoryDays bounds how far back a history export reaches; zero
+	// means no limit.
+	ExportHistoryDays int `json:"export_history_days"`
+}
+
+type Config map[Plan]Entitlements
+
+func DefaultConfig() Config {
+	return Config{
+		PlanFree: {
+			MaxChirpLength:    140,
+			CanEditChirps:     false,
+			RequestsPerMinute: 60,
+			ExportHistoryDays: 30,
+		},
+		PlanRed: {
+			MaxChirpLength:    280,
+			CanEditChirps:     true,
+			RequestsPerMinute: 300,
+			ExportHistoryDays: 0,
+		},
+	}
+}
+
+// LoadConfig reads plan overrides from a JSON file keyed by plan name.
+// Plans and fields missing from the file keep their defaults; a plan with
+// no default starts from the default free plan.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[Plan]json.RawMessage{}
+	err = json.Unmarshal(data, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan config: %w", err)
+	}
+
+	for plan, raw := range overrides {
+		e, ok := cfg[plan]
+		if !ok {
+			e = DefaultConfig()[PlanFree]
+		}
+		err = json.Unmarshal(raw, &e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plan config for %s: %w", plan, err)
+		}
+		cfg[plan] = e
+	}
+
+	return cfg, nil
+}
+
+// For returns the entitlements of plan, falling back to the free plan.
+func (c Config) For(plan Plan) Entitlements {
+	if e, ok := c[plan]; ok {
+		return e
+	}
+	return c[PlanFree]
+}
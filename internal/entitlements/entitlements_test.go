@@ -0,0 +1,94 @@
+package entitlements_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/migomi3/internal/entitlements"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	err := os.WriteFile(path, []byte(`{"chirpy_red": {"max_chirp_length": 500, "can_edit_chirps": true}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := entitlements.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if got := cfg.For(entitlements.PlanRed).MaxChirpLength; got != 500 {
+		t.Errorf("red MaxChirpLength = %d, want 500", got)
+	}
+	if got := cfg.For(entitlements.PlanFree); got != entitlements.DefaultConfig()[entitlements.PlanFree] {
+		t.Errorf("free plan = %+v, want defaults", got)
+	}
+}
+
+func TestLoadConfigPartialOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	err := os.WriteFile(path, []byte(`{"free": {"max_chirp_length": 200}, "platinum": {"requests_per_minute": 1000}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := entitlements.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	defaults := entitlements.DefaultConfig()
+
+	wantFree := defaults[entitlements.PlanFree]
+	wantFree.MaxChirpLength = 200
+	if got := cfg.For(entitlements.PlanFree); got != wantFree {
+		t.Errorf("free plan = %+v, want %+v", got, wantFree)
+	}
+
+	if got := cfg.For(entitlements.PlanRed); got != defaults[entitlements.PlanRed] {
+		t.Errorf("red plan = %+v, want defaults", got)
+	}
+
+	wantPlatinum := defaults[entitlements.PlanFree]
+	wantPlatinum.RequestsPerMinute = 1000
+	if got := cfg.For("platinum"); got != wantPlatinum {
+		t.Errorf("new plan = %+v, want free defaults with override %+v", got, wantPlatinum)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{name: "Malformed JSON", contents: `{`},
+		{name: "Wrong type", contents: `{"free": {"max_chirp_length": "long"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plans.json")
+			if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := entitlements.LoadConfig(path); err == nil {
+				t.Error("LoadConfig() expected error")
+			}
+		})
+	}
+
+	if _, err := entitlements.LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadConfig() expected error for missing file")
+	}
+}
+
+func TestForUnknownPlan(t *testing.T) {
+	cfg := entitlements.DefaultConfig()
+	if got := cfg.For("platinum"); got != cfg[entitlements.PlanFree] {
+		t.Errorf("For(unknown) = %+v, want free plan", got)
+	}
+}
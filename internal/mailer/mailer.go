@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as password resets.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders msg as a plain-text RFC 5322 message.
+func format(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTP sends mail through an SMTP server, authenticating with PLAIN when a
+// username is set.
+type SMTP struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("mailer: header contains a line break")
+	}
+
+	var a smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		a = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, a, s.From, []string{msg.To}, format(s.From, msg, time.Now()))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// File writes each message to its own .eml file in Dir, for local
+// development without a mail server.
+type File struct {
+	Dir  string
+	From string
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	err := os.MkdirAll(f.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString())
+	return os.WriteFile(filepath.Join(f.Dir, name), format(f.From, msg, now), 0o600)
+}
+
+// Memory keeps sent messages in memory for tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
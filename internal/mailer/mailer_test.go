@@ -0,0 +1,72 @@
+package mailer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/migomi3/internal/mailer"
+)
+
+func TestFileSend(t *testing.T) {
+	dir := t.TempDir()
+	m := &mailer.File{Dir: dir, From: "chirpy@example.com"}
+
+	err := m.Send(context.Background(), mailer.Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (err %v)", files, err)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestMemorySend(t *testing.T) {
+	m := &mailer.Memory{}
+	msg := mailer.Message{To: "user@example.com", Subject: "Hi", Body: "Hello"}
+
+	err := m.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := m.Messages()
+	if len(got) != 1 || got[0] != msg {
+		t.Errorf("Messages() = %v, want [%v]", got, msg)
+	}
+}
+
+func TestSMTPRejectsHeaderInjection(t *testing.T) {
+	m := &mailer.SMTP{Addr: "localhost:1", From: "chirpy@example.com"}
+
+	err := m.Send(context.Background(), mailer.Message{
+		To:      "user@example.com\r\nBcc: victim@example.com",
+		Subject: "Hi",
+	})
+	if err == nil {
+		t.Error("Send() expected error for header with line break")
+	}
+}
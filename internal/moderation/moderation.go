@@ -0,0 +1,268 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Action is what happens to a chirp containing a listed word.
+type Action string
+
+const (
+	// ActionMask replaces the word with asterisks and lets the chirp through.
+	ActionMask Action = "mask"
+	// ActionReject refuses the chirp outright.
+	ActionReject Action = "reject"
+	// ActionFlag lets the chirp through unchanged but queues it for review.
+	ActionFlag Action = "flag"
+)
+
+const mask = "****"
+
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionMask, ActionReject, ActionFlag:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown moderation action %q", s)
+}
+
+type Rule struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+// Match is a listed word found in a chirp, in its normalized form.
+type Match struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+// Result is the outcome of checking a chirp. Body has every masked word
+// replaced; it is only meaningful when the chirp was not rejected.
+type Result struct {
+	Body    string
+	Matches []Match
+}
+
+// Has reports whether any match called for action.
+func (r Result) Has(action Action) bool {
+	for _, m := range r.Matches {
+		if m.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Words returns the matched words that called for action.
+func (r Result) Words(action Action) []string {
+	var words []string
+	for _, m := range r.Matches {
+		if m.Action == action {
+			words = append(words, m.Word)
+		}
+	}
+	return words
+}
+
+// Filter checks chirp bodies before they are stored.
+type Filter interface {
+	Check(body string) Result
+}
+
+// DefaultRules is the word list used when no other list is configured.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionMask},
+	}
+}
+
+// LoadRules reads a JSON array of rules from path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid word list: %w", err)
+	}
+
+	return rules, nil
+}
+
+// WordList is a Filter that matches whole words against a list of rules.
+// The list can be swapped while requests are being served.
+type WordList struct {
+	mu    sync.RWMutex
+	rules map[string]Action
+}
+
+func NewWordList(rules []Rule) (*WordList, error) {
+	l := &WordList{}
+	err := l.Replace(rules)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Replace validates rules and swaps them in. On error the current list is
+// left untouched.
+func (l *WordList) Replace(rules []Rule) error {
+	m := make(map[string]Action, len(rules))
+	for _, rule := range rules {
+		word, err := NormalizeWord(rule.Word)
+		if err != nil {
+			return err
+		}
+		action, err := ParseAction(string(rule.Action))
+		if err != nil {
+			return err
+		}
+		m[word] = action
+	}
+
+	l.mu.Lock()
+	l.rules = m
+	l.mu.Unlock()
+	return nil
+}
+
+// Rules returns the current list sorted by word.
+func (l *WordList) Rules() []Rule {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(l.rules))
+	for word, action := range l.rules {
+		rules = append(rules, Rule{Word: word, Action: action})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Word < rules[j].Word })
+	return rules
+}
+
+func (l *WordList) Check(body string) Result {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var b strings.Builder
+	result := Result{}
+	last := 0
+	for _, span := range words(body) {
+		word := normalize(body[span[0]:span[1]])
+		action, ok := l.rules[word]
+		if !ok {
+			continue
+		}
+		result.Matches = append(result.Matches, Match{Word: word, Action: action})
+		if action == ActionMask {
+			b.WriteString(body[last:span[0]])
+			b.WriteString(mask)
+			last = span[1]
+		}
+	}
+	b.WriteString(body[last:])
+	result.Body = b.String()
+
+	return result
+}
+
+// NormalizeWord returns the form a listed word is matched under, and
+// rejects entries that could never match because they span several words.
+func NormalizeWord(word string) (string, error) {
+	spans := words(word)
+	if len(spans) != 1 || spans[0][0] != 0 || spans[0][1] != len(word) {
+		return "", fmt.Errorf("moderation word %q must be a single word", word)
+	}
+
+	n := normalize(word)
+	if n == "" {
+		return "", errors.New("moderation word is empty after normalization")
+	}
+	return n, nil
+}
+
+// words returns the byte spans of the words in s. A word is a run of
+// letters, digits and combining marks; invisible format characters such as
+// zero-width joiners are kept inside it so they can't be used to split a
+// word in two.
+func words(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range s {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(s)})
+	}
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Cf)
+}
+
+// normalize folds a word to a canonical form: compatibility decomposition
+// (so fullwidth and styled letters become plain ones), accents and
+// invisible characters dropped, common look-alikes mapped to Latin, and
+// case folded.
+func normalize(word string) string {
+	t := transform.Chain(
+		norm.NFKD,
+		runes.Remove(runes.In(unicode.Mn)),
+		runes.Remove(runes.In(unicode.Cf)),
+		runes.Map(unconfuse),
+		cases.Fold(),
+		norm.NFC,
+	)
+	n, _, err := transform.String(t, word)
+	if err != nil {
+		return word
+	}
+	return n
+}
+
+// confusables maps Cyrillic and Greek letters that render like Latin ones.
+var confusables = map[rune]rune{
+	'а': 'a', 'А': 'a', 'в': 'b', 'В': 'b', 'е': 'e', 'Е': 'e', 'к': 'k', 'К': 'k',
+	'м': 'm', 'М': 'm', 'н': 'h', 'Н': 'h', 'о': 'o', 'О': 'o', 'р': 'p', 'Р': 'p',
+	'с': 'c', 'С': 'c', 'т': 't', 'Т': 't', 'у': 'y', 'У': 'y', 'х': 'x', 'Х': 'x',
+	'і': 'i', 'І': 'i', 'ј': 'j', 'Ј': 'j', 'ѕ': 's', 'Ѕ': 's',
+	'α': 'a', 'Α': 'a', 'β': 'b', 'Β': 'b', 'ε': 'e', 'Ε': 'e', 'η': 'n', 'Η': 'h',
+	'ι': 'i', 'Ι': 'i', 'κ': 'k', 'Κ': 'k', 'ν': 'v', 'Ν': 'n', 'ο': 'o', 'Ο': 'o',
+	'ρ': 'p', 'Ρ': 'p', 'τ': 't', 'Τ': 't', 'υ': 'u', 'Υ': 'y', 'χ': 'x', 'Χ': 'x',
+	'Ζ': 'z', 'Μ': 'm',
+}
+
+func unconfuse(r rune) rune {
+	if c, ok := confusables[r]; ok {
+		return c
+	}
+	return r
+}
@@ -0,0 +1,93 @@
+package moderation_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/migomi3/internal/moderation"
+)
+
+func TestWordListCheck(t *testing.T) {
+	list, err := moderation.NewWordList([]moderation.Rule{
+		{Word: "kerfuffle", Action: moderation.ActionMask},
+		{Word: "sharbert", Action: moderation.ActionMask},
+		{Word: "fornax", Action: moderation.ActionMask},
+		{Word: "blorp", Action: moderation.ActionReject},
+		{Word: "zorp", Action: moderation.ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("NewWordList() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		reject bool
+		flag   bool
+	}{
+		{name: "Clean", input: "test", want: "test"},
+		{name: "Keeps case of clean words", input: "Test", want: "Test"},
+		{name: "Masked", input: "kerfuffle", want: "****"},
+		{name: "Unlisted words kept", input: "fucking kerfuffle", want: "fucking ****"},
+		{name: "Case insensitive", input: "testing Sharbert", want: "testing ****"},
+		{name: "Trailing punctuation", input: "Fornax!", want: "****!"},
+		{name: "Comma and space", input: "sharbert, ", want: "****, "},
+		{name: "Mixed case", input: "KerFuFFle?", want: "****?"},
+		{name: "Quoted", input: `"kerfuffle"`, want: `"****"`},
+		{name: "Substring not matched", input: "kerfuffles", want: "kerfuffles"},
+		{name: "Fullwidth letters", input: "ｆｏｒｎａｘ", want: "****"},
+		{name: "Accents", input: "fórnàx", want: "****"},
+		{name: "Cyrillic look-alikes", input: "k\u0435rfuffl\u0435", want: "****"},
+		{name: "Zero width space", input: "sharb\u200bert", want: "****"},
+		{name: "Reject", input: "well blorp", want: "well blorp", reject: true},
+		{name: "Flag", input: "zorp!", want: "zorp!", flag: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := list.Check(tt.input)
+			if got.Body != tt.want {
+				t.Errorf("Check(%q).Body = %q, want %q", tt.input, got.Body, tt.want)
+			}
+			if got.Has(moderation.ActionReject) != tt.reject {
+				t.Errorf("Check(%q) rejected = %v, want %v", tt.input, !tt.reject, tt.reject)
+			}
+			if got.Has(moderation.ActionFlag) != tt.flag {
+				t.Errorf("Check(%q) flagged = %v, want %v", tt.input, !tt.flag, tt.flag)
+			}
+		})
+	}
+}
+
+func TestWordListReplace(t *testing.T) {
+	list, err := moderation.NewWordList(moderation.DefaultRules())
+	if err != nil {
+		t.Fatalf("NewWordList() error = %v", err)
+	}
+
+	err = list.Replace([]moderation.Rule{{Word: "two words", Action: moderation.ActionMask}})
+	if err == nil {
+		t.Fatal("Replace() expected error for multi-word entry")
+	}
+	err = list.Replace([]moderation.Rule{{Word: "blorp", Action: "delete"}})
+	if err == nil {
+		t.Fatal("Replace() expected error for unknown action")
+	}
+	if got := list.Check("kerfuffle").Body; got != "****" {
+		t.Errorf("failed Replace() changed the list: Check() = %q", got)
+	}
+
+	err = list.Replace([]moderation.Rule{{Word: "Blorp", Action: moderation.ActionMask}})
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if got := list.Check("kerfuffle blorp").Body; got != "kerfuffle ****" {
+		t.Errorf("Check() = %q, want %q", got, "kerfuffle ****")
+	}
+
+	want := []moderation.Rule{{Word: "blorp", Action: moderation.ActionMask}}
+	if got := list.Rules(); !slices.Equal(got, want) {
+		t.Errorf("Rules() = %v, want %v", got, want)
+	}
+}
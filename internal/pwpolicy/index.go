@@ -0,0 +1,180 @@
+package pwpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// The breach index is a file of SHA-1 hashes grouped by their first two
+// bytes, the way a k-anonymity range API groups them by prefix. A lookup
+// reads one prefix's bucket and searches the suffixes in it, so the corpus
+// never has to be held in memory.
+//
+// Layout, all integers big-endian:
+//
+//	magic    [4]byte "CBP1"
+//	offsets  [65537]uint32  entry index where each prefix's bucket starts
+//	entries  [n][8]byte     bytes 2-9 of each hash, sorted
+//
+// Keeping 8 of the 18 remaining bytes is enough that an unrelated password
+// matching by chance is vanishingly unlikely.
+const (
+	indexMagic   = "CBP1"
+	prefixLength = 2
+	suffixLength = 8
+	buckets      = 1 << (8 * prefixLength)
+	headerLength = len(indexMagic) + 4*(buckets+1)
+)
+
+var ErrInvalidIndex = errors.New("invalid breach index")
+
+// Index is an open breach index file.
+type Index struct {
+	f       *os.File
+	offsets []uint32
+}
+
+func OpenIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerLength)
+	_, err = io.ReadFull(f, header)
+	if err != nil || string(header[:len(indexMagic)]) != indexMagic {
+		f.Close()
+		return nil, ErrInvalidIndex
+	}
+
+	offsets := make([]uint32, buckets+1)
+	for i := range offsets {
+		offsets[i] = binary.BigEndian.Uint32(header[len(indexMagic)+4*i:])
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() != int64(headerLength)+int64(offsets[buckets])*suffixLength {
+		f.Close()
+		return nil, ErrInvalidIndex
+	}
+
+	return &Index{f: f, offsets: offsets}, nil
+}
+
+func (idx *Index) Close() error {
+	return idx.f.Close()
+}
+
+// Len returns the number of hashes in the index.
+func (idx *Index) Len() int {
+	return int(idx.offsets[buckets])
+}
+
+// Range returns the stored suffixes of every hash starting with prefix.
+func (idx *Index) Range(prefix [prefixLength]byte) ([][]byte, error) {
+	p := binary.BigEndian.Uint16(prefix[:])
+	start, end := idx.offsets[p], idx.offsets[p+1]
+	if start == end {
+		return nil, nil
+	}
+
+	buf := make([]byte, int(end-start)*suffixLength)
+	_, err := idx.f.ReadAt(buf, int64(headerLength)+int64(start)*suffixLength)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := make([][]byte, 0, end-start)
+	for i := 0; i < len(buf); i += suffixLength {
+		suffixes = append(suffixes, buf[i:i+suffixLength])
+	}
+	return suffixes, nil
+}
+
+func (idx *Index) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	suffixes, err := idx.Range([prefixLength]byte(sum[:prefixLength]))
+	if err != nil {
+		return false, err
+	}
+
+	want := sum[prefixLength : prefixLength+suffixLength]
+	i := sort.Search(len(suffixes), func(i int) bool {
+		return bytes.Compare(suffixes[i], want) >= 0
+	})
+	return i < len(suffixes) && bytes.Equal(suffixes[i], want), nil
+}
+
+// ReadCorpus reads one entry per line. Lines that are a 40-character hex
+// SHA-1, optionally followed by ":count" as in published hash dumps, are
+// taken as hashes; anything else is taken as a plaintext password.
+func ReadCorpus(r io.Reader) ([][sha1.Size]byte, error) {
+	var hashes [][sha1.Size]byte
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		hexHash, _, _ := strings.Cut(line, ":")
+		if len(hexHash) == 2*sha1.Size {
+			if b, err := hex.DecodeString(hexHash); err == nil {
+				hashes = append(hashes, [sha1.Size]byte(b))
+				continue
+			}
+		}
+		hashes = append(hashes, sha1.Sum([]byte(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// WriteIndex writes hashes to w in the index format, dropping duplicates.
+func WriteIndex(w io.Writer, hashes [][sha1.Size]byte) error {
+	entries := make([][prefixLength + suffixLength]byte, 0, len(hashes))
+	for _, h := range hashes {
+		entries = append(entries, [prefixLength + suffixLength]byte(h[:prefixLength+suffixLength]))
+	}
+	slices.SortFunc(entries, func(a, b [prefixLength + suffixLength]byte) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	entries = slices.Compact(entries)
+	if len(entries) > int(^uint32(0)) {
+		return fmt.Errorf("breach corpus too large: %d entries", len(entries))
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(indexMagic)
+
+	var n uint32
+	for p := 0; p <= buckets; p++ {
+		for int(n) < len(entries) && int(binary.BigEndian.Uint16(entries[n][:prefixLength])) < p {
+			n++
+		}
+		binary.Write(bw, binary.BigEndian, n)
+	}
+
+	for _, e := range entries {
+		bw.Write(e[prefixLength:])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,103 @@
+package pwpolicy_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/migomi3/internal/pwpolicy"
+)
+
+func buildIndex(t *testing.T, corpus string) string {
+	t.Helper()
+
+	hashes, err := pwpolicy.ReadCorpus(strings.NewReader(corpus))
+	if err != nil {
+		t.Fatalf("ReadCorpus() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.idx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = pwpolicy.WriteIndex(f, hashes)
+	if err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	return path
+}
+
+func TestIndexBreached(t *testing.T) {
+	// "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8" is SHA-1("password").
+	corpus := strings.Join([]string{
+		"123456",
+		"qwerty",
+		"qwerty",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493",
+		"",
+		"letmein\r",
+	}, "\n")
+	path := buildIndex(t, corpus)
+
+	idx, err := pwpolicy.OpenIndex(path)
+	if err != nil {
+		t.Fatalf("OpenIndex() error = %v", err)
+	}
+	defer idx.Close()
+
+	if idx.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", idx.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "123456", want: true},
+		{password: "qwerty", want: true},
+		{password: "password", want: true},
+		{password: "letmein", want: true},
+		{password: "correct horse battery", want: false},
+		{password: "1234567", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got, err := idx.Breached(tt.password)
+			if err != nil {
+				t.Fatalf("Breached() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Breached(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenIndexInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.idx")
+	err := os.WriteFile(path, []byte("not an index"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pwpolicy.OpenIndex(path)
+	if err == nil {
+		t.Error("OpenIndex() expected error for invalid file")
+	}
+
+	good := buildIndex(t, "123456\n")
+	data, _ := os.ReadFile(good)
+	err = os.WriteFile(path, data[:len(data)-1], 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pwpolicy.OpenIndex(path)
+	if err == nil {
+		t.Error("OpenIndex() expected error for truncated file")
+	}
+}
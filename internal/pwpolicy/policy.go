@@ -0,0 +1,105 @@
+// Package pwpolicy decides which passwords are acceptable for new or changed
+// credentials.
+package pwpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeContainsEmail = "contains_email"
+	CodeBreached      = "breached"
+)
+
+// Violation is one reason a password was refused.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// BreachChecker reports whether a password is known from a breach.
+type BreachChecker interface {
+	Breached(password string) (bool, error)
+}
+
+// Policy lengths are counted in characters, not bytes.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Breached, when set, rejects passwords found in a breach corpus.
+	Breached BreachChecker
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength: 8,
+		MaxLength: 128,
+	}
+}
+
+// Check returns every rule password breaks for the account at email. The
+// error is only for a failing breach lookup.
+func (p Policy) Check(password, email string) ([]Violation, error) {
+	var violations []Violation
+
+	n := utf8.RuneCountInString(password)
+	if n < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("Password must be at most %d characters", p.MaxLength),
+		})
+		// Don't spend a breach lookup on a password that is refused anyway
+		// and may be arbitrarily large.
+		return violations, nil
+	}
+
+	if containsEmail(password, email) {
+		violations = append(violations, Violation{
+			Code:    CodeContainsEmail,
+			Message: "Password must not contain your email address",
+		})
+	}
+
+	if p.Breached != nil && n > 0 {
+		breached, err := p.Breached.Breached(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Code:    CodeBreached,
+				Message: "Password has appeared in a data breach; choose a different one",
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// minLocalPartLength keeps short local parts like "al" from ruling out
+// every password that happens to contain them.
+const minLocalPartLength = 3
+
+func containsEmail(password, email string) bool {
+	if email == "" {
+		return false
+	}
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+
+	if strings.Contains(password, email) {
+		return true
+	}
+	local, _, ok := strings.Cut(email, "@")
+	return ok && len(local) >= minLocalPartLength && strings.Contains(password, local)
+}
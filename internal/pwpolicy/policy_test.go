@@ -0,0 +1,75 @@
+package pwpolicy_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/migomi3/internal/pwpolicy"
+)
+
+type breachList []string
+
+func (b breachList) Breached(password string) (bool, error) {
+	return slices.Contains(b, password), nil
+}
+
+type failingChecker struct{}
+
+func (failingChecker) Breached(string) (bool, error) {
+	return false, errors.New("index unavailable")
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := pwpolicy.DefaultPolicy()
+	policy.Breached = breachList{"password123"}
+
+	tests := []struct {
+		name      string
+		password  string
+		email     string
+		wantCodes []string
+	}{
+		{name: "Acceptable", password: "correct horse battery", email: "walt@breakingbad.com"},
+		{name: "Empty", password: "", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeTooShort}},
+		{name: "Short", password: "abc", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeTooShort}},
+		{name: "Counts characters not bytes", password: "ééééééééé", email: "walt@breakingbad.com"},
+		{name: "Long", password: strings.Repeat("a", 129), email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeTooLong}},
+		{name: "Contains email", password: "xWalt@BreakingBad.comx", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeContainsEmail}},
+		{name: "Contains local part", password: "heisenberg-walt-99", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeContainsEmail}},
+		{name: "Short local part ignored", password: "my alibi is solid", email: "al@example.com"},
+		{name: "Breached", password: "password123", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeBreached}},
+		{name: "Several", password: "walt", email: "walt@breakingbad.com", wantCodes: []string{pwpolicy.CodeTooShort, pwpolicy.CodeContainsEmail}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Check(tt.password, tt.email)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			var codes []string
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+				if v.Message == "" {
+					t.Errorf("violation %s has no message", v.Code)
+				}
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("Check() codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckBreachError(t *testing.T) {
+	policy := pwpolicy.DefaultPolicy()
+	policy.Breached = failingChecker{}
+
+	_, err := policy.Check("correct horse battery", "walt@breakingbad.com")
+	if err == nil {
+		t.Error("Check() expected error from breach checker")
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in process. Buckets that have refilled
+// completely are dropped, since a missing bucket behaves the same as a full
+// one.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// sweepEvery is how many takes happen between passes that drop full
+// buckets.
+const sweepEvery = 4096
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true, Limit: limit}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	capacity := limit.capacity()
+	rate := limit.rate()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		m.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.last = now
+	}
+
+	res := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+
+	m.takes++
+	if m.takes >= sweepEvery {
+		m.takes = 0
+		for k, v := range m.buckets {
+			if !now.Before(v.full) {
+				delete(m.buckets, k)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,140 @@
+// Package ratelimit implements token-bucket request limits. Buckets live
+// in a Store so they can be moved out of process later.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Limit allows Requests per Period on average, with bursts of up to Burst
+// (Requests when unset). A zero Limit means no limit.
+type Limit struct {
+	Requests int      `json:"requests"`
+	Period   Duration `json:"period"`
+	Burst    int      `json:"burst,omitempty"`
+}
+
+func PerMinute(n int) Limit {
+	return Limit{Requests: n, Period: Duration(time.Minute)}
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate is tokens added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / time.Duration(l.Period).Seconds()
+}
+
+// Duration reads durations from JSON as strings such as "1m" or "1h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Result describes a bucket after one request was taken from it.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// SetHeaders writes the RateLimit-* fields and, for denied requests,
+// Retry-After.
+func (r Result) SetHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(int(r.Limit.capacity())))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(r.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", r.Limit.Requests, ceilSeconds(time.Duration(r.Limit.Period))))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(r.RetryAfter))))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type Store interface {
+	// Take removes one token from the bucket for key, refilling it first for
+	// the time since it was last used.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Policy is the limit for one route. Plans overrides Limit for callers on
+// the named plan.
+type Policy struct {
+	Limit
+	Plans map[string]Limit `json:"plans,omitempty"`
+}
+
+// For returns the limit that applies to plan.
+func (p Policy) For(plan string) Limit {
+	if l, ok := p.Plans[plan]; ok {
+		return l
+	}
+	return p.Limit
+}
+
+// Policies maps ServeMux patterns, such as "POST /api/chirps", to their
+// limits.
+type Policies map[string]Policy
+
+// LoadPolicies reads route policies from a JSON file keyed by pattern.
+// Routes in the file replace the matching entry in defaults.
+func LoadPolicies(path string, defaults Policies) (Policies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := Policies{}
+	err = json.Unmarshal(data, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit config: %w", err)
+	}
+
+	policies := make(Policies, len(defaults)+len(overrides))
+	for pattern, p := range defaults {
+		policies[pattern] = p
+	}
+	for pattern, p := range overrides {
+		policies[pattern] = p
+	}
+	return policies, nil
+}
@@ -0,0 +1,148 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/ratelimit"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	s := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 3, Period: ratelimit.Duration(3 * time.Second)}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, wantRemaining := range []int{2, 1, 0} {
+		res, err := s.Take(ctx, "walt", limit, now)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !res.Allowed || res.Remaining != wantRemaining {
+			t.Errorf("request %d: Allowed = %v, Remaining = %d, want true, %d", i, res.Allowed, res.Remaining, wantRemaining)
+		}
+	}
+
+	res, _ := s.Take(ctx, "walt", limit, now)
+	if res.Allowed {
+		t.Error("request over the limit was allowed")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("Reset = %v, want 3s", res.Reset)
+	}
+
+	res, _ = s.Take(ctx, "jesse", limit, now)
+	if !res.Allowed {
+		t.Error("other key was limited")
+	}
+
+	res, _ = s.Take(ctx, "walt", limit, now.Add(time.Second))
+	if !res.Allowed {
+		t.Error("request after refill was denied")
+	}
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	ctx := context.Background()
+	s := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 60, Period: ratelimit.Duration(time.Minute), Burst: 2}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s.Take(ctx, "walt", limit, now)
+	s.Take(ctx, "walt", limit, now)
+	res, _ := s.Take(ctx, "walt", limit, now)
+	if res.Allowed {
+		t.Error("request past the burst was allowed")
+	}
+}
+
+func TestMemoryStoreUnlimited(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	for range 100 {
+		res, _ := s.Take(context.Background(), "walt", ratelimit.Limit{}, time.Now())
+		if !res.Allowed {
+			t.Fatal("zero limit denied a request")
+		}
+	}
+}
+
+func TestResultSetHeaders(t *testing.T) {
+	h := http.Header{}
+	ratelimit.Result{
+		Allowed:    false,
+		Limit:      ratelimit.PerMinute(60),
+		Remaining:  0,
+		Reset:      1500 * time.Millisecond,
+		RetryAfter: 200 * time.Millisecond,
+	}.SetHeaders(h)
+
+	want := map[string]string{
+		"RateLimit-Limit":     "60",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+		"RateLimit-Policy":    "60;w=60",
+		"Retry-After":         "1",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	p := ratelimit.Policy{
+		Limit: ratelimit.PerMinute(10),
+		Plans: map[string]ratelimit.Limit{"chirpy_red": ratelimit.PerMinute(30)},
+	}
+	if got := p.For("free"); got != ratelimit.PerMinute(10) {
+		t.Errorf("For(free) = %+v", got)
+	}
+	if got := p.For("chirpy_red"); got != ratelimit.PerMinute(30) {
+		t.Errorf("For(chirpy_red) = %+v", got)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.json")
+	err := os.WriteFile(path, []byte(`{
+		"POST /api/chirps": {"requests": 5, "period": "1m", "plans": {"chirpy_red": {"requests": 20, "period": "1m"}}}
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := ratelimit.Policies{
+		"POST /api/users":  {Limit: ratelimit.Limit{Requests: 5, Period: ratelimit.Duration(time.Hour)}},
+		"POST /api/chirps": {Limit: ratelimit.PerMinute(10)},
+	}
+	policies, err := ratelimit.LoadPolicies(path, defaults)
+	if err != nil {
+		t.Fatalf("LoadPolicies() error = %v", err)
+	}
+
+	if got := policies["POST /api/chirps"].For("free"); got != ratelimit.PerMinute(5) {
+		t.Errorf("chirps free = %+v", got)
+	}
+	if got := policies["POST /api/chirps"].For("chirpy_red"); got != ratelimit.PerMinute(20) {
+		t.Errorf("chirps red = %+v", got)
+	}
+	if _, ok := policies["POST /api/users"]; !ok {
+		t.Error("default policy missing after load")
+	}
+
+	err = os.WriteFile(path, []byte(`{"POST /api/chirps": {"period": "soon"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ratelimit.LoadPolicies(path, defaults); err == nil {
+		t.Error("LoadPolicies() expected error for bad duration")
+	}
+}
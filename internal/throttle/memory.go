@@ -0,0 +1,68 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps state in process. Entries are dropped once their window
+// has passed, so it stays bounded by the number of keys that failed
+// recently.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	sweeps  int
+}
+
+type memoryEntry struct {
+	State
+	expiresAt time.Time
+}
+
+// sweepEvery is how many writes happen between passes that drop expired
+// entries.
+const sweepEvery = 1024
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[key].State, nil
+}
+
+func (m *MemoryStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key]
+	if now.After(e.expiresAt) {
+		e = memoryEntry{}
+	}
+	e.Failures++
+	e.LastFailure = now
+	e.expiresAt = now.Add(window)
+	m.entries[key] = e
+
+	m.sweeps++
+	if m.sweeps >= sweepEvery {
+		m.sweeps = 0
+		for k, v := range m.entries {
+			if now.After(v.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+
+	return e.State, nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package throttle slows down repeated failures, such as wrong passwords,
+// with exponential backoff and a temporary lockout. Failure counts live in
+// a Store so they can be shared between instances.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrBackoff = errors.New("too many failed attempts, try again later")
+	ErrLocked  = errors.New("temporarily locked after too many failed attempts")
+)
+
+// State is what a Store remembers about one key.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+type Store interface {
+	// Get returns the state for key, or the zero State if nothing is stored.
+	Get(ctx context.Context, key string) (State, error)
+	// Fail records a failure at now and returns the new state. A previous
+	// failure older than window is forgotten first.
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (State, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy sets how quickly a key is slowed down.
+type Policy struct {
+	// FreeAttempts failures are allowed before any delay applies.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts. It
+	// doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key for LockoutDuration.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy}
+}
+
+// Wait returns how long key must wait before its next attempt given state.
+// Stale state counts as no failures at all.
+func (p Policy) Wait(state State, now time.Time) (time.Duration, error) {
+	if state.Failures == 0 || now.Sub(state.LastFailure) > p.Window {
+		return 0, nil
+	}
+
+	if p.LockoutAfter > 0 && state.Failures >= p.LockoutAfter {
+		if wait := state.LastFailure.Add(p.LockoutDuration).Sub(now); wait > 0 {
+			return wait, ErrLocked
+		}
+		return 0, nil
+	}
+
+	over := state.Failures - p.FreeAttempts
+	if over <= 0 {
+		return 0, nil
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	if wait := state.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait, ErrBackoff
+	}
+	return 0, nil
+}
+
+// Allow reports whether key may make an attempt now. When it may not, the
+// returned duration is how long to wait and the error is ErrBackoff or
+// ErrLocked.
+func (l *Limiter) Allow(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	state, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return l.policy.Wait(state, now)
+}
+
+// Fail records a failed attempt for key.
+func (l *Limiter) Fail(ctx context.Context, key string, now time.Time) error {
+	_, err := l.store.Fail(ctx, key, now, l.policy.Window)
+	return err
+}
+
+// Succeed forgets key's failures.
+func (l *Limiter) Succeed(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
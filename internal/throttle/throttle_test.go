@@ -0,0 +1,98 @@
+package throttle_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/throttle"
+)
+
+var testPolicy = throttle.Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        8 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func TestPolicyWait(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		state    throttle.State
+		wantWait time.Duration
+		wantErr  error
+	}{
+		{name: "No failures"},
+		{name: "Within free attempts", state: throttle.State{Failures: 3, LastFailure: now}},
+		{name: "First delay", state: throttle.State{Failures: 4, LastFailure: now}, wantWait: time.Second, wantErr: throttle.ErrBackoff},
+		{name: "Doubles", state: throttle.State{Failures: 6, LastFailure: now}, wantWait: 4 * time.Second, wantErr: throttle.ErrBackoff},
+		{name: "Capped", state: throttle.State{Failures: 9, LastFailure: now}, wantWait: 8 * time.Second, wantErr: throttle.ErrBackoff},
+		{name: "Delay elapsed", state: throttle.State{Failures: 5, LastFailure: now.Add(-3 * time.Second)}},
+		{name: "Partly elapsed", state: throttle.State{Failures: 5, LastFailure: now.Add(-time.Second)}, wantWait: time.Second, wantErr: throttle.ErrBackoff},
+		{name: "Locked", state: throttle.State{Failures: 10, LastFailure: now.Add(-5 * time.Minute)}, wantWait: 10 * time.Minute, wantErr: throttle.ErrLocked},
+		{name: "Lockout over", state: throttle.State{Failures: 10, LastFailure: now.Add(-16 * time.Minute)}},
+		{name: "Stale", state: throttle.State{Failures: 50, LastFailure: now.Add(-2 * time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, err := testPolicy.Wait(tt.state, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Wait() error = %v, want %v", err, tt.wantErr)
+			}
+			if wait != tt.wantWait {
+				t.Errorf("Wait() = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := throttle.NewLimiter(throttle.NewMemoryStore(), testPolicy)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		err := l.Fail(ctx, "walt", now)
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+	}
+
+	wait, err := l.Allow(ctx, "walt", now)
+	if !errors.Is(err, throttle.ErrBackoff) || wait != time.Second {
+		t.Errorf("Allow() = %v, %v, want 1s, ErrBackoff", wait, err)
+	}
+
+	_, err = l.Allow(ctx, "jesse", now)
+	if err != nil {
+		t.Errorf("Allow() for another key error = %v", err)
+	}
+
+	err = l.Succeed(ctx, "walt")
+	if err != nil {
+		t.Fatalf("Succeed() error = %v", err)
+	}
+	_, err = l.Allow(ctx, "walt", now)
+	if err != nil {
+		t.Errorf("Allow() after Succeed() error = %v", err)
+	}
+}
+
+func TestMemoryStoreForgetsAfterWindow(t *testing.T) {
+	ctx := context.Background()
+	s := throttle.NewMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s.Fail(ctx, "walt", now, time.Minute)
+	s.Fail(ctx, "walt", now, time.Minute)
+	state, _ := s.Fail(ctx, "walt", now.Add(2*time.Minute), time.Minute)
+	if state.Failures != 1 {
+		t.Errorf("Failures = %d, want 1 after window passed", state.Failures)
+	}
+}
@@ -0,0 +1,110 @@
+package totp_test
+
+import (
+	"bytes"
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/totp"
+)
+
+// The SHA1 vectors from RFC 6238 appendix B, truncated to six digits.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := totp.CodeAt(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("CodeAt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CodeAt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := totp.Step(now)
+	prev, _ := totp.CodeAt(rfcSecret, step-1)
+	old, _ := totp.CodeAt(rfcSecret, step-2)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "Current", code: "005924", wantStep: step, wantOK: true},
+		{name: "Spaced", code: " 005 924 ", wantStep: step, wantOK: true},
+		{name: "Previous step", code: prev, wantStep: step - 1, wantOK: true},
+		{name: "Outside skew", code: old},
+		{name: "Wrong", code: "123456"},
+		{name: "Too short", code: "05924"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := totp.Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret has invalid length: %d", len(secret))
+	}
+	_, err = totp.CodeAt(secret, 1)
+	if err != nil {
+		t.Errorf("CodeAt() rejected generated secret: %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	raw := totp.ProvisioningURI("Chirpy", "walt@breakingbad.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:walt@breakingbad.com" {
+		t.Errorf("unexpected URI %s", raw)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Chirpy" || q.Get("digits") != "6" {
+		t.Errorf("unexpected query %v", q)
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	png, err := totp.QRCode("otpauth://totp/Chirpy:walt@breakingbad.com?secret=JBSWY3DPEHPK3PXP", 256)
+	if err != nil {
+		t.Fatalf("QRCode() error = %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Error("QRCode() did not return a PNG")
+	}
+}
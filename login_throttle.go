@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/throttle"
+)
+
+const (
+	authEventLoginSucceeded     = "login_succeeded"
+	authEventLoginFailed        = "login_failed"
+	authEventLoginThrottled     = "login_throttled"
+	authEventTwoFactorRequired  = "two_factor_required"
+	authEventTwoFactorSucceeded = "two_factor_succeeded"
+	authEventTwoFactorFailed    = "two_factor_failed"
+)
+
+// accountLoginPolicy protects a single account: a few typos are free, then
+// each failure doubles the wait, and ten in a row lock the account briefly.
+var accountLoginPolicy = throttle.Policy{
+	FreeAttempts:    5,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+// ipLoginPolicy is looser, since many users can share an address, but stops
+// one client spraying passwords across many accounts.
+var ipLoginPolicy = throttle.Policy{
+	FreeAttempts:    20,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAfter:    100,
+	LockoutDuration: time.Hour,
+	Window:          time.Hour,
+}
+
+// dummyPasswordHash is checked against when the email is unknown, so a
+// missing account takes as long to reject as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := auth.HashPassword("chirpy-dummy-password")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return hash
+})
+
+// loginAttempt names the throttle keys one attempt counts against. account
+// is the email for the password step and the user ID for the second factor.
+type loginAttempt struct {
+	accountKey string
+	ipKey      string
+}
+
+func newLoginAttempt(r *http.Request, account string) loginAttempt {
+	return loginAttempt{
+		accountKey: "login:" + account,
+		ipKey:      "login-ip:" + clientIP(r),
+	}
+}
+
+// allowLogin returns the longer of the account and IP waits.
+func (cfg *apiConfig) allowLogin(ctx context.Context, a loginAttempt) (time.Duration, error) {
+	now := time.Now()
+	accountWait, accountErr := cfg.accountThrottle.Allow(ctx, a.accountKey, now)
+	ipWait, ipErr := cfg.ipThrottle.Allow(ctx, a.ipKey, now)
+	return max(accountWait, ipWait), errors.Join(accountErr, ipErr)
+}
+
+func (cfg *apiConfig) failLogin(ctx context.Context, a loginAttempt) {
+	now := time.Now()
+	err := errors.Join(
+		cfg.accountThrottle.Fail(ctx, a.accountKey, now),
+		cfg.ipThrottle.Fail(ctx, a.ipKey, now),
+	)
+	if err != nil {
+		log.Printf("Error recording failed login: %s", err)
+	}
+}
+
+// succeedLogin clears the account's failures. The IP's are kept so a client
+// with one working password can't use it to reset its budget for guessing
+// others.
+func (cfg *apiConfig) succeedLogin(ctx context.Context, a loginAttempt) {
+	err := cfg.accountThrottle.Succeed(ctx, a.accountKey)
+	if err != nil {
+		log.Printf("Error clearing login failures: %s", err)
+	}
+}
+
+func respondWithLoginThrottleError(w http.ResponseWriter, wait time.Duration, err error) {
+	if !errors.Is(err, throttle.ErrBackoff) && !errors.Is(err, throttle.ErrLocked) {
+		respondWithError(w, http.StatusInternalServerError, "Error checking login attempts", err)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many login attempts, try again later", err)
+}
+
+// recordAuthEvent appends to the auth_events log. A failure to log never
+// fails the login itself.
+func (cfg *apiConfig) recordAuthEvent(r *http.Request, event string, userID uuid.NullUUID, email string) {
+	err := cfg.db.RecordAuthEvent(r.Context(), database.RecordAuthEventParams{
+		Event:     event,
+		UserID:    userID,
+		Email:     email,
+		IpAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		log.Printf("Error recording auth event %s: %s", event, err)
+	}
+}
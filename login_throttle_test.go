@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/throttle"
+)
+
+func TestRespondWithLoginThrottleError(t *testing.T) {
+	tests := []struct {
+		name           string
+		wait           time.Duration
+		err            error
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{name: "Backoff rounds up", wait: 1500 * time.Millisecond, err: throttle.ErrBackoff, wantCode: http.StatusTooManyRequests, wantRetryAfter: "2"},
+		{name: "Locked", wait: 15 * time.Minute, err: throttle.ErrLocked, wantCode: http.StatusTooManyRequests, wantRetryAfter: "900"},
+		{name: "Joined", wait: time.Second, err: errors.Join(nil, throttle.ErrBackoff), wantCode: http.StatusTooManyRequests, wantRetryAfter: "1"},
+		{name: "Store error", err: errors.New("store unavailable"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			respondWithLoginThrottleError(rec, tt.wait, tt.err)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
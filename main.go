@@ -1,23 +1,58 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/migomi3/internal/auth"
 	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/entitlements"
+	"github.com/migomi3/internal/mailer"
+	"github.com/migomi3/internal/moderation"
+	"github.com/migomi3/internal/pwpolicy"
+	"github.com/migomi3/internal/ratelimit"
+	"github.com/migomi3/internal/throttle"
 )
 
+const refreshTokenTTL = time.Hour * 1440
+
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
-	secret         string
-	polkaKey       string
+	// keys signs the tokens Chirpy issues and verifies them by kid.
+	keys      *auth.Keyring
+	polkaKeys []string
+	plans     entitlements.Config
+	// chirpEditWindow is how long after posting an author may edit a chirp.
+	chirpEditWindow time.Duration
+	wordList        *moderation.WordList
+	// wordListFile, when set, replaces the moderation_words table as the
+	// source of the word list.
+	wordListFile string
+	mailer       mailer.Mailer
+	// accountThrottle and ipThrottle slow down repeated login failures.
+	accountThrottle *throttle.Limiter
+	ipThrottle      *throttle.Limiter
+	// rateLimits are per-route overrides of the plan's general limit.
+	rateLimits  ratelimit.Policies
+	rateLimiter ratelimit.Store
+	// passwordPolicy is applied whenever a password is set.
+	passwordPolicy pwpolicy.Policy
+	// publicURL is the base for links sent in emails.
+	publicURL string
 }
 
 func main() {
@@ -25,40 +60,154 @@ func main() {
 
 	dbURL := os.Getenv("DB_URL")
 	pf := os.Getenv("PLATFORM")
-	secret := os.Getenv("SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
+	// POLKA_KEYS holds every signing secret currently accepted, comma
+	// separated, so a new key can be rolled out before the old one is dropped.
+	polkaKeys := splitList(os.Getenv("POLKA_KEYS"))
+	if len(polkaKeys) == 0 {
+		polkaKeys = splitList(os.Getenv("POLKA_KEY"))
+	}
+
+	plans := entitlements.DefaultConfig()
+	if plansFile := os.Getenv("PLANS_FILE"); plansFile != "" {
+		var err error
+		plans, err = entitlements.LoadConfig(plansFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	rateLimits := defaultRateLimits
+	if limitsFile := os.Getenv("RATE_LIMITS_FILE"); limitsFile != "" {
+		var err error
+		rateLimits, err = ratelimit.LoadPolicies(limitsFile, defaultRateLimits)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	passwordPolicy, err := passwordPolicyFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	chirpEditWindow := defaultChirpEditWindow
+	if window := os.Getenv("CHIRP_EDIT_WINDOW"); window != "" {
+		var err error
+		chirpEditWindow, err = time.ParseDuration(window)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	mux := http.NewServeMux()
-	server := http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), db, os.Args[1:])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
 	}
 
+	keys, err := keyringFromEnv(pf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	loginThrottleStore := throttle.NewMemoryStore()
 	cfg := apiConfig{
-		db:       database.New(db),
-		platform: pf,
-		secret:   secret,
-		polkaKey: polkaKey,
+		db:        database.New(db),
+		dbConn:    db,
+		platform:  pf,
+		keys:      keys,
+		polkaKeys: polkaKeys,
+		plans:     plans,
+
+		chirpEditWindow: chirpEditWindow,
+		wordListFile:    os.Getenv("MODERATION_WORDS_FILE"),
+		mailer:          mailerFromEnv(),
+		accountThrottle: throttle.NewLimiter(loginThrottleStore, accountLoginPolicy),
+		ipThrottle:      throttle.NewLimiter(loginThrottleStore, ipLoginPolicy),
+		rateLimits:      rateLimits,
+		rateLimiter:     ratelimit.NewMemoryStore(),
+		passwordPolicy:  passwordPolicy,
+		publicURL:       strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/"),
+	}
+	if cfg.publicURL == "" {
+		cfg.publicURL = "http://localhost:8080"
 	}
 	cfg.fileserverHits.Store(0)
 
+	rules, err := cfg.loadModerationRules(context.Background())
+	if err != nil {
+		if cfg.wordListFile != "" {
+			log.Fatalln(err)
+		}
+		log.Printf("Error loading moderation word list, using defaults: %s", err)
+		rules = moderation.DefaultRules()
+	}
+	cfg.wordList, err = moderation.NewWordList(rules)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	mux := http.NewServeMux()
+	server := http.Server{
+		Addr:    ":8080",
+		Handler: cfg.middlewareRateLimit(mux, cfg.middlewareAccountStatus(mux)),
+	}
+
+	go cfg.expireSubscriptions(subscriptionSweepInterval)
+	go cfg.refreshWordList(wordListRefreshInterval)
+
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.jwksHandler)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.upgradeUserHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirpHandler)
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", cfg.editChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.chirpRevisionsHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.threadHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.likeHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.unlikeHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", cfg.rechirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", cfg.undoRechirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", cfg.reportChirpHandler)
+	mux.HandleFunc("GET /api/warnings", cfg.warningsHandler)
 	mux.HandleFunc("PUT /api/users", cfg.updateUserHandler)
 	mux.HandleFunc("POST /api/revoke", cfg.revokeHandler)
 	mux.HandleFunc("POST /api/refresh", cfg.refreshHandler)
 	mux.HandleFunc("POST /api/login", cfg.loginHandler)
+	mux.HandleFunc("POST /api/login/2fa", cfg.loginTwoFactorHandler)
+	mux.HandleFunc("POST /api/2fa/totp", cfg.enrollTOTPHandler)
+	mux.HandleFunc("GET /api/2fa/totp/qr.png", cfg.totpQRHandler)
+	mux.HandleFunc("POST /api/2fa/totp/confirm", cfg.confirmTOTPHandler)
+	mux.HandleFunc("DELETE /api/2fa/totp", cfg.disableTOTPHandler)
+	mux.HandleFunc("POST /api/2fa/recovery-codes", cfg.recoveryCodesHandler)
+	mux.HandleFunc("POST /api/password/forgot", cfg.forgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", cfg.resetPasswordHandler)
+	mux.HandleFunc("POST /api/email/verify", cfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/email/verify/resend", cfg.resendVerificationHandler)
 	mux.HandleFunc("POST /api/users", cfg.usersHandler)
 	mux.HandleFunc("POST /api/chirps", cfg.chirpsHandler)
+	mux.HandleFunc("POST /api/chirps/validate", cfg.validateChirpHandler)
 	mux.HandleFunc("GET /api/chirps", cfg.getAllChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{id}", cfg.getChirpHandler)
-	mux.HandleFunc("POST /admin/reset", cfg.resetHandler)
-	mux.HandleFunc("GET /admin/metrics", cfg.metricsHandler)
-	mux.HandleFunc("GET /admin/healthz", cfg.healthEndpointHandler)
+	mux.HandleFunc("GET /api/export/chirps", cfg.exportChirpsHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.followHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.unfollowHandler)
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.followersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.followingHandler)
+	mux.HandleFunc("GET /api/timeline", cfg.timelineHandler)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.hashtagChirpsHandler)
+	mux.HandleFunc("GET /api/search/chirps", cfg.searchChirpsHandler)
+	mux.HandleFunc("GET /api/subscriptions", cfg.subscriptionsHandler)
+	mux.HandleFunc("GET /api/sessions", cfg.listSessionsHandler)
+	mux.HandleFunc("DELETE /api/sessions", cfg.revokeAllSessionsHandler)
+	mux.HandleFunc("DELETE /api/sessions/{id}", cfg.revokeSessionHandler)
+
+	mux.Handle("/admin/", cfg.adminHandler())
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir("./")))))
 
 	err = server.ListenAndServe()
@@ -66,3 +215,127 @@ func main() {
 		log.Fatalln(err)
 	}
 }
+
+// adminHandler serves every /admin/ route. They are all registered here,
+// behind the admin check, so none can be added without it.
+func (cfg *apiConfig) adminHandler() http.Handler {
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/users/{userID}/sessions", cfg.adminListSessionsHandler)
+	adminMux.HandleFunc("GET /admin/users/{userID}/auth-events", cfg.adminAuthEventsHandler)
+	adminMux.HandleFunc("PUT /admin/users/{userID}/status", cfg.setAccountStatusHandler)
+	adminMux.HandleFunc("PUT /admin/users/{userID}/role", cfg.setRoleHandler)
+	adminMux.HandleFunc("GET /admin/moderation/words", cfg.listModerationWordsHandler)
+	adminMux.HandleFunc("PUT /admin/moderation/words/{word}", cfg.putModerationWordHandler)
+	adminMux.HandleFunc("DELETE /admin/moderation/words/{word}", cfg.deleteModerationWordHandler)
+	adminMux.HandleFunc("GET /admin/moderation/flags", cfg.listChirpFlagsHandler)
+	adminMux.HandleFunc("GET /admin/reports", cfg.listReportsHandler)
+	adminMux.HandleFunc("POST /admin/reports/{reportID}/resolve", cfg.resolveReportHandler)
+	adminMux.HandleFunc("POST /admin/reset", cfg.resetHandler)
+	adminMux.HandleFunc("GET /admin/metrics", cfg.metricsHandler)
+	adminMux.HandleFunc("GET /admin/healthz", cfg.healthEndpointHandler)
+
+	return cfg.middlewareRequireRole(auth.RoleAdmin, adminMux)
+}
+
+// mailerFromEnv sends through SMTP_ADDR when it is set. Otherwise mail is
+// written to MAIL_DIR (default "mail") so development needs no mail server.
+func mailerFromEnv() mailer.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return &mailer.SMTP{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "mail"
+	}
+	log.Printf("SMTP_ADDR not set, writing outgoing mail to %s", dir)
+	return &mailer.File{Dir: dir, From: from}
+}
+
+// keyringFromEnv signs with the PEM private key at JWT_SIGNING_KEY and
+// still accepts tokens from the keys listed in JWT_RETIRED_KEYS, comma
+// separated, so a key can be rotated out once its tokens have expired.
+// Only the dev platform may run without a signing key; it gets a throwaway
+// one, which invalidates every token on restart. SECRET, the HS256 secret
+// used before signing keys, is still accepted for verification while the
+// tokens it signed run out.
+func keyringFromEnv(platform string) (*auth.Keyring, error) {
+	var active *auth.Key
+	if path := os.Getenv("JWT_SIGNING_KEY"); path != "" {
+		var err error
+		active, err = auth.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+	} else if platform == "dev" {
+		var err error
+		active, err = auth.GenerateKey(auth.AlgEdDSA)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("JWT_SIGNING_KEY not set, signing with ephemeral key %s", active.ID)
+	} else {
+		return nil, errors.New("JWT_SIGNING_KEY is required, create one with `chirpy generate-signing-key`")
+	}
+
+	var retired []*auth.Key
+	for _, path := range splitList(os.Getenv("JWT_RETIRED_KEYS")) {
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, key)
+	}
+
+	keys, err := auth.NewKeyring(active, retired...)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret := os.Getenv("SECRET"); secret != "" {
+		log.Printf("SECRET set, accepting legacy HS256 tokens; unset it once they have expired")
+		keys.AcceptLegacySecret(secret)
+	}
+
+	return keys, nil
+}
+
+// passwordPolicyFromEnv applies PASSWORD_MIN_LENGTH and PASSWORD_MAX_LENGTH
+// to the default policy and, when BREACHED_PASSWORDS_INDEX names an index
+// built by `chirpy build-breach-index`, rejects passwords found in it.
+func passwordPolicyFromEnv() (pwpolicy.Policy, error) {
+	policy := pwpolicy.DefaultPolicy()
+
+	for env, limit := range map[string]*int{
+		"PASSWORD_MIN_LENGTH": &policy.MinLength,
+		"PASSWORD_MAX_LENGTH": &policy.MaxLength,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return pwpolicy.Policy{}, fmt.Errorf("invalid %s %q", env, v)
+			}
+			*limit = n
+		}
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_INDEX"); path != "" {
+		idx, err := pwpolicy.OpenIndex(path)
+		if err != nil {
+			return pwpolicy.Policy{}, fmt.Errorf("opening %s: %w", path, err)
+		}
+		policy.Breached = idx
+	}
+
+	return policy, nil
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/entitlements"
+	"github.com/migomi3/internal/ratelimit"
 )
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -10,3 +17,119 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// middlewareRequireRole only lets through requests whose JWT carries at
+// least role. The role is checked against the database as well, so a
+// demotion takes effect before the token expires.
+func (cfg *apiConfig) middlewareRequireRole(role auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+			return
+		}
+
+		claims, err := auth.ParseJWT(token, cfg.keys)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+			return
+		}
+
+		if !claims.Role.Allows(role) {
+			respondWithError(w, http.StatusForbidden, "Unauthorized access", fmt.Errorf("role %s required", role))
+			return
+		}
+
+		u, err := cfg.db.GetUserFromID(r.Context(), claims.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "User not found", err)
+			return
+		}
+
+		if !auth.Role(u.Role).Allows(role) {
+			respondWithError(w, http.StatusForbidden, "Unauthorized access", fmt.Errorf("role %s required", role))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultRateLimits are the routes that don't share the general per-plan
+// limit. A zero policy exempts a route entirely.
+var defaultRateLimits = ratelimit.Policies{
+	"POST /api/chirps": {
+		Limit: ratelimit.PerMinute(10),
+		Plans: map[string]ratelimit.Limit{string(entitlements.PlanRed): ratelimit.PerMinute(30)},
+	},
+	"POST /api/chirps/{chirpID}/report": {Limit: ratelimit.Limit{Requests: 20, Period: ratelimit.Duration(time.Hour)}},
+	"POST /api/users":                   {Limit: ratelimit.Limit{Requests: 5, Period: ratelimit.Duration(time.Hour)}},
+	"POST /api/login":                   {Limit: ratelimit.PerMinute(20)},
+	"POST /api/login/2fa":               {Limit: ratelimit.PerMinute(10)},
+	"POST /api/password/forgot":         {Limit: ratelimit.Limit{Requests: 5, Period: ratelimit.Duration(time.Hour)}},
+	// Static files and partner webhooks aren't limited.
+	"/app/":                    {},
+	"POST /api/polka/webhooks": {},
+}
+
+// middlewareRateLimit applies the policy of the route mux would dispatch r
+// to. Routes without a policy share one bucket per caller, sized by the
+// caller's plan. Store errors let the request through rather than taking
+// the API down with the limiter.
+func (cfg *apiConfig) middlewareRateLimit(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		identity, plan := cfg.rateLimitIdentity(r)
+
+		scope := "default"
+		limit := ratelimit.PerMinute(cfg.plans.For(plan).RequestsPerMinute)
+		if p, ok := cfg.rateLimits[pattern]; ok {
+			scope = pattern
+			limit = p.For(string(plan))
+		}
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := cfg.rateLimiter.Take(r.Context(), scope+"|"+identity, limit, time.Now())
+		if err != nil {
+			log.Printf("Error checking rate limit: %s", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res.SetHeaders(w.Header())
+		if !res.Allowed {
+			respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitIdentity keys a request by user when it carries a valid JWT, by
+// API key when it carries one of the configured keys, and by client IP
+// otherwise. Unverified credentials fall through to the IP, or a caller
+// could mint a fresh bucket per request. Only users can be on a paid plan.
+func (cfg *apiConfig) rateLimitIdentity(r *http.Request) (string, entitlements.Plan) {
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		if userID, err := auth.ValidateJWT(token, cfg.keys); err == nil {
+			plan := entitlements.PlanFree
+			isChirpyRed, err := cfg.db.IsChirpyRed(r.Context(), userID)
+			if err != nil {
+				log.Printf("Error checking subscription for rate limit: %s", err)
+			} else if isChirpyRed {
+				plan = entitlements.PlanRed
+			}
+			return "user:" + userID.String(), plan
+		}
+	}
+
+	if key, err := auth.GetAPIKey(r.Header); err == nil && auth.ValidAPIKey(key, cfg.polkaKeys) {
+		return "apikey:" + auth.HashToken(key), entitlements.PlanFree
+	}
+
+	return "ip:" + clientIP(r), entitlements.PlanFree
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/entitlements"
+	"github.com/migomi3/internal/ratelimit"
+)
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	plans := entitlements.DefaultConfig()
+	free := plans[entitlements.PlanFree]
+	free.RequestsPerMinute = 3
+	plans[entitlements.PlanFree] = free
+
+	cfg := &apiConfig{
+		plans: plans,
+		rateLimits: ratelimit.Policies{
+			"POST /api/users": {Limit: ratelimit.Limit{Requests: 1, Period: ratelimit.Duration(time.Hour)}},
+			"/app/":           {},
+		},
+		rateLimiter: ratelimit.NewMemoryStore(),
+	}
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux.HandleFunc("GET /api/chirps", ok)
+	mux.HandleFunc("POST /api/users", ok)
+	mux.HandleFunc("/app/", ok)
+	handler := cfg.middlewareRateLimit(mux, mux)
+
+	do := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		ip            string
+		wantCode      int
+		wantRemaining string
+	}{
+		{name: "Default 1", method: "GET", path: "/api/chirps", ip: "10.0.0.1", wantCode: 200, wantRemaining: "2"},
+		{name: "Default 2", method: "GET", path: "/api/chirps", ip: "10.0.0.1", wantCode: 200, wantRemaining: "1"},
+		{name: "Route policy has its own bucket", method: "POST", path: "/api/users", ip: "10.0.0.1", wantCode: 200, wantRemaining: "0"},
+		{name: "Route policy exhausted", method: "POST", path: "/api/users", ip: "10.0.0.1", wantCode: 429, wantRemaining: "0"},
+		{name: "Default 3", method: "GET", path: "/api/chirps", ip: "10.0.0.1", wantCode: 200, wantRemaining: "0"},
+		{name: "Default exhausted", method: "GET", path: "/api/chirps", ip: "10.0.0.1", wantCode: 429, wantRemaining: "0"},
+		{name: "Other IP", method: "GET", path: "/api/chirps", ip: "10.0.0.2", wantCode: 200, wantRemaining: "2"},
+		{name: "Exempt", method: "GET", path: "/app/index.html", ip: "10.0.0.1", wantCode: 200},
+	}
+
+	for _, tt := range tests {
+		rec := do(tt.method, tt.path, tt.ip)
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantCode)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%s: RateLimit-Remaining = %q, want %q", tt.name, got, tt.wantRemaining)
+		}
+		if tt.wantCode == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: missing Retry-After", tt.name)
+		}
+	}
+}
+
+func TestMiddlewareRateLimitIgnoresUnverifiedCredentials(t *testing.T) {
+	cfg := &apiConfig{
+		keys:      testKeyring(t),
+		polkaKeys: []string{"polka_key"},
+		plans:     entitlements.DefaultConfig(),
+		rateLimits: ratelimit.Policies{
+			"POST /api/login": {Limit: ratelimit.Limit{Requests: 1, Period: ratelimit.Duration(time.Hour)}},
+		},
+		rateLimiter: ratelimit.NewMemoryStore(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := cfg.middlewareRateLimit(mux, mux)
+
+	// Made-up credentials all share the caller's IP bucket; only a
+	// configured API key gets a bucket of its own.
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{name: "Anonymous", wantCode: http.StatusOK},
+		{name: "Unknown API key", header: "ApiKey first", wantCode: http.StatusTooManyRequests},
+		{name: "Another unknown API key", header: "ApiKey second", wantCode: http.StatusTooManyRequests},
+		{name: "Invalid JWT", header: "Bearer not-a-jwt", wantCode: http.StatusTooManyRequests},
+		{name: "Configured API key", header: "ApiKey polka_key", wantCode: http.StatusOK},
+		{name: "Configured API key exhausted", header: "ApiKey polka_key", wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantCode)
+		}
+	}
+}
+
+func testKeyring(t *testing.T) *auth.Keyring {
+	t.Helper()
+	key, err := auth.GenerateKey(auth.AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeyring(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
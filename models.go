@@ -1,22 +1,253 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/entities"
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Handle        string    `json:"handle,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email,omitempty"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+}
+
+func userFromDB(u database.User, isChirpyRed bool) User {
+	return User{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		Handle:        u.Handle.String,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		PendingEmail:  u.PendingEmail.String,
+		IsChirpyRed:   isChirpyRed,
+	}
+}
+
+type AccountStatus struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	Status         string     `json:"status"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+type UserRole struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+// TwoFactorChallenge is returned by login in place of a User when the
+// account has two-factor authentication enabled.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCodeURL       string `json:"qr_code_url"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// FieldError is one problem with one request field. Code is stable for
+// clients to branch on; Message is for display.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
 }
 
 type LoginParameters struct {
 	Password string `json:"password"`
 	Email    string `json:"email"`
+	// Handle is only read when updating a user.
+	Handle string `json:"handle"`
+}
+
+type Chirp struct {
+	ID            uuid.UUID         `json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Body          string            `json:"body"`
+	UserID        uuid.UUID         `json:"user_id"`
+	Edited        bool              `json:"edited"`
+	InReplyTo     *uuid.UUID        `json:"in_reply_to"`
+	ReplyCount    int32             `json:"reply_count"`
+	Deleted       bool              `json:"deleted"`
+	Hidden        bool              `json:"hidden"`
+	LikeCount     int32             `json:"like_count"`
+	RechirpCount  int32             `json:"rechirp_count"`
+	Entities      entities.Entities `json:"entities"`
+	LikedByMe     *bool             `json:"liked_by_me,omitempty"`
+	RechirpedByMe *bool             `json:"rechirped_by_me,omitempty"`
+}
+
+// ChirpValidation reports how a body would be counted if posted. Remaining
+// goes negative when the body is over the limit.
+type ChirpValidation struct {
+	Valid     bool   `json:"valid"`
+	Length    int    `json:"length"`
+	MaxLength int    `json:"max_length"`
+	Remaining int    `json:"remaining"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ChirpsPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+func chirpFromDB(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		Body:         c.Body,
+		UserID:       c.UserID,
+		Edited:       c.EditedAt.Valid,
+		ReplyCount:   c.ReplyCount,
+		Deleted:      c.DeletedAt.Valid,
+		Hidden:       c.HiddenAt.Valid,
+		LikeCount:    c.LikeCount,
+		RechirpCount: c.RechirpCount,
+	}
+	if c.InReplyTo.Valid {
+		chirp.InReplyTo = &c.InReplyTo.UUID
+	}
+	// Hidden chirps only surface inside threads, where they keep their place
+	// but not their text.
+	if chirp.Hidden {
+		chirp.Body = ""
+		c.Entities = nil
+	}
+	chirp.Entities = entities.Entities{
+		Hashtags: []entities.Hashtag{},
+		Mentions: []entities.Mention{},
+	}
+	// The column is only ever written from a marshalled Entities, and
+	// tombstones reset it to an empty object.
+	_ = json.Unmarshal(c.Entities, &chirp.Entities)
+	return chirp
+}
+
+// SearchResult is a chirp matched by full-text search. Snippet is the
+// HTML-escaped body with matched terms wrapped in <mark> tags, safe to
+// insert into a page as is.
+type SearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Words     []string  `json:"words"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	ChirpID    uuid.UUID  `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Resolution string     `json:"resolution,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	AuthorID   uuid.UUID  `json:"author_id"`
+	ChirpBody  string     `json:"chirp_body"`
+	Hidden     bool       `json:"hidden"`
+	Deleted    bool       `json:"deleted"`
+}
+
+type ReportsPage struct {
+	Reports    []Report `json:"reports"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type Warning struct {
+	ID        uuid.UUID  `json:"id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowsPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+type AuthEvent struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Event     string    `json:"event"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+}
+
+type Subscription struct {
+	ID                 uuid.UUID  `json:"id"`
+	Status             string     `json:"status"`
+	CurrentPeriodStart time.Time  `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time  `json:"current_period_end"`
+	EndedAt            *time.Time `json:"ended_at"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type ThreadNode struct {
+	Chirp
+	Replies []ThreadNode `json:"replies"`
+}
+
+type Thread struct {
+	Ancestors []Chirp    `json:"ancestors"`
+	Chirp     ThreadNode `json:"chirp"`
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/database"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor is the keyset position of the last row on a page.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// firstPageCursor returns a position that sorts before every row in the
+// requested direction, so the first page can use the same keyset query.
+func firstPageCursor(desc bool) pageCursor {
+	if desc {
+		return pageCursor{
+			CreatedAt: time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC),
+			ID:        uuid.Max,
+		}
+	}
+	return pageCursor{
+		CreatedAt: time.Time{},
+		ID:        uuid.Nil,
+	}
+}
+
+func encodeCursor(c pageCursor) string {
+	raw := fmt.Sprintf("%d:%s", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, err
+	}
+
+	return parseCursor(string(raw))
+}
+
+func parseCursor(raw string) (pageCursor, error) {
+	nanos, idString, ok := strings.Cut(raw, ":")
+	if !ok {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+
+	return pageCursor{
+		CreatedAt: time.Unix(0, n).UTC(),
+		ID:        id,
+	}, nil
+}
+
+// searchCursor extends pageCursor with the rank of the last result, since
+// search results are ordered by relevance before recency.
+type searchCursor struct {
+	Rank float32
+	pageCursor
+}
+
+func firstSearchCursor() searchCursor {
+	return searchCursor{
+		Rank:       math.MaxFloat32,
+		pageCursor: firstPageCursor(true),
+	}
+}
+
+func encodeSearchCursor(c searchCursor) string {
+	rank := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32)
+	raw := fmt.Sprintf("%s:%d:%s", rank, c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(s string) (searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return searchCursor{}, err
+	}
+
+	rankString, rest, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+
+	rank, err := strconv.ParseFloat(rankString, 32)
+	if err != nil {
+		return searchCursor{}, fmt.Errorf("invalid cursor rank: %w", err)
+	}
+
+	c, err := parseCursor(rest)
+	if err != nil {
+		return searchCursor{}, err
+	}
+
+	return searchCursor{Rank: float32(rank), pageCursor: c}, nil
+}
+
+// parsePageParams reads the limit and cursor query parameters, defaulting to
+// the first page in the given direction.
+func parsePageParams(r *http.Request, desc bool) (int32, pageCursor, error) {
+	query := r.URL.Query()
+
+	pageSize, err := parsePageSize(query.Get("limit"))
+	if err != nil {
+		return 0, pageCursor{}, err
+	}
+
+	cursor := firstPageCursor(desc)
+	if c := query.Get("cursor"); c != "" {
+		cursor, err = decodeCursor(c)
+		if err != nil {
+			return 0, pageCursor{}, err
+		}
+	}
+
+	return pageSize, cursor, nil
+}
+
+func parsePageSize(s string) (int32, error) {
+	if s == "" {
+		return defaultPageSize, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+
+	return int32(n), nil
+}
+
+// newChirpsPage trims a result fetched with pageSize+1 rows down to
+// pageSize and sets the cursor when another page follows.
+func newChirpsPage(chirps []database.Chirp, pageSize int32) ChirpsPage {
+	page := ChirpsPage{
+		Chirps: make([]Chirp, 0, len(chirps)),
+	}
+	if len(chirps) > int(pageSize) {
+		chirps = chirps[:pageSize]
+		last := chirps[len(chirps)-1]
+		page.NextCursor = encodeCursor(pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	for _, c := range chirps {
+		page.Chirps = append(page.Chirps, chirpFromDB(c))
+	}
+	return page
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := pageCursor{
+		CreatedAt: time.Date(2025, time.February, 14, 10, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("decodeCursor() = %v, want %v", got, want)
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		want searchCursor
+	}{
+		{
+			name: "Fractional rank",
+			want: searchCursor{
+				Rank: 0.0607927,
+				pageCursor: pageCursor{
+					CreatedAt: time.Date(2025, time.March, 2, 8, 0, 0, 42, time.UTC),
+					ID:        uuid.New(),
+				},
+			},
+		},
+		{
+			name: "Zero rank",
+			want: searchCursor{
+				pageCursor: pageCursor{
+					CreatedAt: time.Date(2024, time.December, 31, 23, 59, 59, 0, time.UTC),
+					ID:        uuid.New(),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSearchCursor(encodeSearchCursor(tt.want))
+			if err != nil {
+				t.Fatalf("decodeSearchCursor() error = %v", err)
+			}
+			if got.Rank != tt.want.Rank || !got.CreatedAt.Equal(tt.want.CreatedAt) || got.ID != tt.want.ID {
+				t.Errorf("decodeSearchCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Not base64", cursor: "!!!"},
+		{name: "Missing separator", cursor: "MTIzNDU"},
+		{name: "Bad timestamp", cursor: "YWJjOjEyMw"},
+		{name: "Bad id", cursor: "MTIzOm5vdC1hLXV1aWQ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) expected error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int32
+		wantErr bool
+	}{
+		{name: "Default", input: "", want: defaultPageSize},
+		{name: "Valid", input: "50", want: 50},
+		{name: "Max", input: "100", want: maxPageSize},
+		{name: "Zero", input: "0", wantErr: true},
+		{name: "Too large", input: "101", wantErr: true},
+		{name: "Not a number", input: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePageSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePageSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parsePageSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
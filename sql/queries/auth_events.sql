@@ -0,0 +1,10 @@
+-- name: RecordAuthEvent :exec
+INSERT INTO auth_events (id, created_at, event, user_id, email, ip_address, user_agent)
+VALUES (gen_random_uuid(), Now(), $1, $2, $3, $4, $5);
+
+-- name: ListAuthEventsForUser :many
+SELECT *
+FROM auth_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
@@ -0,0 +1,31 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, chirp_created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: ClearChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: ClearChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListChirpsByHashtag :many
+SELECT chirps.*
+FROM chirp_hashtags h
+JOIN chirps ON chirps.id = h.chirp_id
+WHERE h.tag = sqlc.arg(tag)
+  AND chirps.deleted_at IS NULL
+  AND chirps.hidden_at IS NULL
+  AND (chirps.user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+  AND (h.chirp_created_at, h.chirp_id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY h.chirp_created_at DESC, h.chirp_id DESC
+LIMIT sqlc.arg(page_size);
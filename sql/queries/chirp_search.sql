@@ -0,0 +1,23 @@
+-- name: SearchChirps :many
+-- websearch_to_tsquery accepts "quoted phrases", OR and -exclusions, and
+-- never fails on malformed input. Results are keyset-paginated on
+-- (rank, created_at, id). Matches in the snippet are delimited by the
+-- private-use characters U+E000 and U+E001, stripped from the body first,
+-- so the caller can escape the text before turning them into markup.
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_count, chirps.entities, chirps.hidden_at,
+    ts_rank(chirps.search_document, q)::real AS rank,
+    ts_headline('english', translate(chirps.body, E'\uE000\uE001', ''), q, E'StartSel=\uE000, StopSel=\uE001, HighlightAll=true')::text AS snippet
+FROM chirps
+CROSS JOIN websearch_to_tsquery('english', sqlc.arg(query)::text) AS q
+WHERE chirps.search_document @@ q
+  AND chirps.deleted_at IS NULL
+  AND chirps.hidden_at IS NULL
+  AND (chirps.user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+  AND chirps.created_at >= sqlc.arg(since)::timestamp
+  AND chirps.created_at < sqlc.arg(until)::timestamp
+  AND (ts_rank(chirps.search_document, q), chirps.created_at, chirps.id) < (sqlc.arg(before_rank)::real, sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -1,27 +1,174 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), Now(), Now(), $1, $2)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, entities)
+VALUES (gen_random_uuid(), Now(), Now(), $1, $2, $3, $4)
 RETURNING *;
 
 -- name: ClearChirps :exec
 DELETE FROM chirps;
 
--- name: GetAllChirps :many
+-- name: ListChirpsAsc :many
 SELECT *
 FROM chirps
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+  AND (user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
 
--- name: GetChirpsFromUser :many
+-- name: ListChirpsDesc :many
 SELECT *
 FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+  AND (user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsFromUserAsc :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+  AND (user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsFromUserDesc :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+  AND (user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirp :one
 SELECT *
 FROM chirps
 WHERE id = $1;
 
+-- name: GetChirpForUpdate :one
+SELECT *
+FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, entities = $3, updated_at = Now(), edited_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: TombstoneChirp :one
+UPDATE chirps
+SET body = '', entities = '{}', updated_at = Now(), deleted_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: IncrementReplyCount :execrows
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count - 1
+WHERE id = $1 AND reply_count > 0;
+
+-- name: AdjustLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + sqlc.arg(delta)::int
+WHERE id = sqlc.arg(id);
+
+-- name: AdjustRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count + sqlc.arg(delta)::int
+WHERE id = sqlc.arg(id);
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = Now()
+WHERE id = $1;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListTimeline :many
+SELECT *
+FROM chirps
+WHERE user_id IN (
+    SELECT followee_id
+    FROM follows
+    WHERE follower_id = sqlc.arg(follower_id)
+)
+  AND deleted_at IS NULL AND hidden_at IS NULL
+  AND (created_at, id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+  AND (user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.status = 'shadowbanned'
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsForExport :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND created_at >= sqlc.arg(since)::timestamp
+  AND deleted_at IS NULL
+  AND (created_at, id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT c.*, 1 AS depth
+    FROM chirps c
+    WHERE c.id = (SELECT in_reply_to FROM chirps WHERE id = sqlc.arg(chirp_id)::uuid)
+    UNION ALL
+    SELECT p.*, a.depth + 1
+    FROM chirps p
+    JOIN ancestors a ON p.id = a.in_reply_to
+    WHERE a.depth < sqlc.arg(max_depth)::int
+)
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM ancestors
+WHERE user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = ancestors.user_id AND users.status = 'shadowbanned'
+)
+ORDER BY depth DESC;
+
+-- name: ListChirpDescendants :many
+-- Hidden replies are filtered inside the recursion, so their whole branch
+-- is pruned: nothing comes back without its parent, and nothing the viewer
+-- can't see counts against max_replies.
+WITH RECURSIVE descendants AS (
+    SELECT c.*, 1 AS depth
+    FROM chirps c
+    WHERE c.in_reply_to = sqlc.arg(chirp_id)::uuid
+      AND (c.user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = c.user_id AND users.status = 'shadowbanned'
+      ))
+    UNION ALL
+    SELECT r.*, d.depth + 1
+    FROM chirps r
+    JOIN descendants d ON r.in_reply_to = d.id
+    WHERE d.depth < sqlc.arg(max_depth)::int
+      AND (r.user_id = sqlc.narg(viewer_id)::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = r.user_id AND users.status = 'shadowbanned'
+      ))
+)
+SELECT id, created_at, updated_at, body, user_id, edited_at, in_reply_to, reply_count, deleted_at, like_count, rechirp_count, entities, search_document, hidden_at
+FROM descendants
+ORDER BY depth, created_at, id
+LIMIT sqlc.arg(max_replies);
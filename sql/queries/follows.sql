@@ -0,0 +1,24 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, Now())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT follower_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg(followee_id)
+  AND (created_at, follower_id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowing :many
+SELECT followee_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg(follower_id)
+  AND (created_at, followee_id) < (sqlc.arg(before_created_at)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,26 @@
+-- name: ListModerationWords :many
+SELECT *
+FROM moderation_words
+ORDER BY word;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (word, action, created_at, updated_at)
+VALUES ($1, $2, Now(), Now())
+ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action, updated_at = Now()
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = $1;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, words, created_at)
+VALUES ($1, $2, Now())
+ON CONFLICT (chirp_id) DO UPDATE
+SET words = EXCLUDED.words, created_at = Now();
+
+-- name: ListChirpFlags :many
+SELECT *
+FROM chirp_flags
+ORDER BY created_at DESC;
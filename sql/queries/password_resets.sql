@@ -0,0 +1,15 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, Now(), $3)
+RETURNING *;
+
+-- name: GetPasswordResetTokenForUpdate :one
+SELECT *
+FROM password_reset_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: UsePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = Now()
+WHERE user_id = $1 AND used_at IS NULL;
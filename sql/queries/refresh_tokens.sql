@@ -1,6 +1,6 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
-VALUES ($1, Now(), Now(), $2, $3, NULL)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip_address)
+VALUES ($1, Now(), Now(), $2, $3, NULL, $4, $5, $6)
 RETURNING *;
 
 -- name: ClearTokens :exec
@@ -11,8 +11,34 @@ SELECT *
 FROM refresh_tokens
 WHERE token = $1;
 
--- name: RevokeToken :one
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET rotated_at = Now(), updated_at = Now()
+WHERE token = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = Now(), updated_at = Now()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListSessions :many
+SELECT family_id,
+    (SELECT min(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS created_at,
+    created_at AS last_used_at,
+    expires_at,
+    user_agent,
+    ip_address
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > Now()
+ORDER BY refresh_tokens.created_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = Now(), updated_at = Now()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllSessions :exec
 UPDATE refresh_tokens
 SET revoked_at = Now(), updated_at = Now()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+WHERE user_id = $1 AND revoked_at IS NULL;
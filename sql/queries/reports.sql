@@ -0,0 +1,31 @@
+-- name: CreateReport :execrows
+INSERT INTO reports (id, chirp_id, reporter_id, reason, details, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, Now())
+ON CONFLICT (chirp_id, reporter_id) WHERE status = 'open' DO NOTHING;
+
+-- name: ListReports :many
+SELECT r.id, r.chirp_id, r.reporter_id, r.reason, r.details, r.status, r.created_at, r.resolution, r.resolved_by, r.resolved_at, c.user_id AS author_id, c.body, c.hidden_at, c.deleted_at
+FROM reports r
+JOIN chirps c ON c.id = r.chirp_id
+WHERE r.status = sqlc.arg(status)
+  AND (r.created_at, r.id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY r.created_at ASC, r.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetReportForUpdate :one
+SELECT *
+FROM reports
+WHERE id = $1
+FOR UPDATE;
+
+-- name: DismissReport :exec
+UPDATE reports
+SET status = 'dismissed', resolution = 'dismiss', resolved_by = $2, resolved_at = Now()
+WHERE id = $1;
+
+-- name: ActionChirpReports :execrows
+-- Acting on a chirp settles every open report against it, not just the one
+-- the moderator picked from the queue.
+UPDATE reports
+SET status = 'actioned', resolution = $2, resolved_by = $3, resolved_at = Now()
+WHERE chirp_id = $1 AND status = 'open';
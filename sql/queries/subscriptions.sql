@@ -0,0 +1,38 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, status, current_period_start, current_period_end, ended_at)
+VALUES (gen_random_uuid(), Now(), Now(), $1, 'active', $2, $3, NULL)
+RETURNING *;
+
+-- name: GetActiveSubscription :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1 AND status = 'active';
+
+-- name: RenewSubscription :one
+UPDATE subscriptions
+SET current_period_end = $2, updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: EndSubscription :execrows
+UPDATE subscriptions
+SET status = $2, ended_at = Now(), updated_at = Now()
+WHERE user_id = $1 AND status = 'active';
+
+-- name: ExpireLapsedSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', ended_at = current_period_end, updated_at = Now()
+WHERE status = 'active' AND current_period_end <= Now();
+
+-- name: IsChirpyRed :one
+SELECT EXISTS (
+    SELECT 1
+    FROM subscriptions
+    WHERE user_id = $1 AND status = 'active' AND current_period_end > Now()
+) AS is_chirpy_red;
+
+-- name: ListSubscriptions :many
+SELECT *
+FROM subscriptions
+WHERE user_id = $1
+ORDER BY created_at DESC;
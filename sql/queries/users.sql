@@ -16,14 +16,54 @@ SELECT *
 FROM users
 WHERE id = $1;
 
--- name: UpdateLoginInfo :one
+-- name: GetUserByHandle :one
+SELECT *
+FROM users
+WHERE lower(handle) = lower(sqlc.arg(handle)::text);
+
+-- name: SetUserHandle :one
 UPDATE users
-SET email = $1, hashed_password = $2
-WHERE id = $3
+SET handle = $2, updated_at = Now()
+WHERE id = $1
 RETURNING *;
 
--- name: UpgradeUser :one
+-- name: SetPendingEmail :one
 UPDATE users
-SET is_chirpy_red = true
+SET pending_email = $2, updated_at = Now()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified_at = Now(), updated_at = Now()
+WHERE id = $1 AND email = $2
+RETURNING *;
+
+-- name: ConfirmEmailChange :one
+UPDATE users
+SET email = pending_email, pending_email = NULL, email_verified_at = Now(), updated_at = Now()
+WHERE id = $1 AND pending_email = $2
+RETURNING *;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = $2, suspended_until = $3, updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdatePassword :one
+UPDATE users
+SET hashed_password = $2, updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: RehashPassword :execrows
+UPDATE users
+SET hashed_password = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id) AND hashed_password = sqlc.arg(old_hash);
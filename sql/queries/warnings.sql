@@ -0,0 +1,10 @@
+-- name: CreateWarning :one
+INSERT INTO warnings (id, user_id, chirp_id, moderator_id, reason, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, Now())
+RETURNING *;
+
+-- name: ListWarnings :many
+SELECT *
+FROM warnings
+WHERE user_id = $1
+ORDER BY created_at DESC;
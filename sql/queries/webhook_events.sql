@@ -0,0 +1,4 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event, received_at)
+VALUES ($1, $2, Now())
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id),
+    FOREIGN KEY (follower_id)
+    References users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (followee_id)
+    References users(id)
+    ON DELETE CASCADE
+);
+
+-- The primary key serves the timeline lookup of everyone a user follows.
+-- These two back the keyset-paginated follower/following listings.
+CREATE INDEX follows_followee_id_created_at_idx ON follows (followee_id, created_at, follower_id);
+CREATE INDEX follows_follower_id_created_at_idx ON follows (follower_id, created_at, followee_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS follows;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+-- Only the newest token of each family is live, so listing sessions only
+-- ever needs to look at tokens that are neither rotated nor revoked.
+CREATE INDEX refresh_tokens_live_user_id_idx ON refresh_tokens (user_id)
+WHERE revoked_at IS NULL AND rotated_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS refresh_tokens_live_user_id_idx;
+ALTER TABLE refresh_tokens
+DROP COLUMN ip_address,
+DROP COLUMN user_agent;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'canceled', 'expired')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX subscriptions_one_active_per_user_idx ON subscriptions (user_id)
+WHERE status = 'active';
+CREATE INDEX subscriptions_active_period_end_idx ON subscriptions (current_period_end)
+WHERE status = 'active';
+
+-- Carry existing members over with a fresh billing period.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, status, current_period_start, current_period_end, ended_at)
+SELECT gen_random_uuid(), Now(), Now(), id, 'active', Now(), Now() + INTERVAL '30 days', NULL
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOL NOT NULL DEFAULT false;
+
+UPDATE users
+SET is_chirpy_red = true
+WHERE id IN (
+    SELECT user_id
+    FROM subscriptions
+    WHERE status = 'active' AND current_period_end > Now()
+);
+
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN edited_at TIMESTAMP;
+
+CREATE TABLE chirp_revisions (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    replaced_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions (chirp_id, replaced_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_revisions;
+ALTER TABLE chirps
+DROP COLUMN edited_at;
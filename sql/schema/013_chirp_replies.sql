@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to, created_at)
+WHERE in_reply_to IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_in_reply_to_idx;
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN reply_count,
+DROP COLUMN in_reply_to;
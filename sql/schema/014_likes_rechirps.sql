@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE chirp_likes (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE rechirps (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_likes_chirp_id_idx ON chirp_likes (chirp_id);
+CREATE INDEX rechirps_chirp_id_idx ON rechirps (chirp_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS rechirps;
+DROP TABLE IF EXISTS chirp_likes;
+ALTER TABLE chirps
+DROP COLUMN rechirp_count,
+DROP COLUMN like_count;
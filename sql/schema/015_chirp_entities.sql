@@ -0,0 +1,47 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN entities JSONB NOT NULL DEFAULT '{}';
+
+-- Mentions are written @handle. Handles are public and optional, unlike
+-- emails, which must never be confirmable by mentioning them.
+ALTER TABLE users
+ADD COLUMN handle TEXT;
+
+CREATE UNIQUE INDEX users_handle_lower_key ON users (lower(handle));
+
+CREATE TABLE chirp_hashtags (
+    chirp_id UUID NOT NULL,
+    tag TEXT NOT NULL,
+    chirp_created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, tag),
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE
+);
+
+-- chirp_created_at is copied from the chirp so a tag page can be
+-- keyset-paginated from this index alone.
+CREATE INDEX chirp_hashtags_tag_created_at_idx ON chirp_hashtags (tag, chirp_created_at, chirp_id);
+
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    PRIMARY KEY (chirp_id, user_id),
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_mentions;
+DROP TABLE IF EXISTS chirp_hashtags;
+DROP INDEX IF EXISTS users_handle_lower_key;
+ALTER TABLE users
+DROP COLUMN handle;
+ALTER TABLE chirps
+DROP COLUMN entities;
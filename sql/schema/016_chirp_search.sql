@@ -0,0 +1,11 @@
+-- +goose Up
+-- A generated column can't drift from the body, whichever path writes it.
+ALTER TABLE chirps
+ADD COLUMN search_document TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_document_idx ON chirps USING GIN (search_document);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_search_document_idx;
+ALTER TABLE chirps
+DROP COLUMN search_document;
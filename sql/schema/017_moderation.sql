@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO moderation_words (word, action, created_at, updated_at)
+VALUES ('kerfuffle', 'mask', Now(), Now()),
+    ('sharbert', 'mask', Now(), Now()),
+    ('fornax', 'mask', Now(), Now());
+
+CREATE TABLE chirp_flags (
+    chirp_id UUID PRIMARY KEY,
+    words TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_flags;
+DROP TABLE IF EXISTS moderation_words;
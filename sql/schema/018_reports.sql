@@ -0,0 +1,55 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    reporter_id UUID NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'actioned')),
+    created_at TIMESTAMP NOT NULL,
+    resolution TEXT CHECK (resolution IN ('dismiss', 'hide', 'delete')),
+    resolved_by UUID,
+    resolved_at TIMESTAMP,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (reporter_id)
+    References users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by)
+    References users(id)
+    ON DELETE SET NULL
+);
+
+-- A user can only have one open report against a chirp at a time.
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (chirp_id, reporter_id) WHERE status = 'open';
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at, id);
+
+CREATE TABLE warnings (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    chirp_id UUID,
+    moderator_id UUID,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id)
+    References chirps(id)
+    ON DELETE SET NULL,
+    FOREIGN KEY (moderator_id)
+    References users(id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX warnings_user_id_idx ON warnings (user_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS warnings;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended', 'banned', 'shadowbanned')),
+ADD COLUMN suspended_until TIMESTAMP,
+ADD CONSTRAINT users_suspended_until_check CHECK ((status = 'suspended') = (suspended_until IS NOT NULL));
+
+-- Listings filter out shadowbanned authors on every request.
+CREATE INDEX users_shadowbanned_idx ON users (id) WHERE status = 'shadowbanned';
+
+-- +goose Down
+DROP INDEX IF EXISTS users_shadowbanned_idx;
+ALTER TABLE users
+DROP CONSTRAINT users_suspended_until_check,
+DROP COLUMN suspended_until,
+DROP COLUMN status;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Two accounts whose addresses differ only by case can't both be
+-- lowercased under the UNIQUE constraint. Refuse to guess which one owns
+-- the mailbox: list them so an operator can merge or rename one first.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(email || ' (' || ids || ')', '; ' ORDER BY email)
+    INTO duplicates
+    FROM (
+        SELECT lower(btrim(email)) AS email, string_agg(id::text, ', ' ORDER BY created_at) AS ids
+        FROM users
+        GROUP BY lower(btrim(email))
+        HAVING count(*) > 1
+    ) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users share an email address apart from case, resolve before migrating: %', duplicates;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+UPDATE users
+SET email = lower(btrim(email));
+
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP,
+ADD COLUMN pending_email TEXT;
+
+-- Accounts created before verification existed keep posting; only new
+-- addresses have to be confirmed.
+UPDATE users
+SET email_verified_at = created_at;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN pending_email,
+DROP COLUMN email_verified_at;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE totp_credentials (
+    user_id UUID PRIMARY KEY,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    UNIQUE (user_id, code_hash),
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_credentials;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE auth_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    user_id UUID,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    FOREIGN KEY (user_id)
+    References users(id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX auth_events_user_id_created_at_idx ON auth_events (user_id, created_at);
+CREATE INDEX auth_events_ip_address_created_at_idx ON auth_events (ip_address, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_events;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Addresses are stored normalized, but the constraint on email alone would
+-- still accept a differently-cased copy written by anything that skips
+-- emailaddr.Normalize.
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+
+-- +goose Down
+DROP INDEX IF EXISTS users_email_lower_key;
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migomi3/internal/auth"
+	"github.com/migomi3/internal/database"
+	"github.com/migomi3/internal/entitlements"
+)
+
+const (
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+
+	// subscriptionPeriod is used when Polka doesn't tell us when the paid
+	// period ends.
+	subscriptionPeriod = 30 * 24 * time.Hour
+
+	subscriptionSweepInterval = 10 * time.Minute
+)
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    uuid.UUID  `json:"user_id"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+// applyPolkaEvent updates the user's subscription for a verified webhook
+// event. Unknown events are ignored.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, event polkaEvent, now time.Time) error {
+	userID := event.Data.UserID
+
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed":
+		_, err := q.GetUserFromID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		periodEnd := now.Add(subscriptionPeriod)
+		if event.Data.PeriodEnd != nil {
+			periodEnd = *event.Data.PeriodEnd
+		}
+
+		sub, err := q.GetActiveSubscription(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = q.CreateSubscription(ctx, database.CreateSubscriptionParams{
+				UserID:             userID,
+				CurrentPeriodStart: now,
+				CurrentPeriodEnd:   periodEnd,
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		// Deliveries can arrive out of order; never shorten a paid period.
+		if periodEnd.Before(sub.CurrentPeriodEnd) {
+			return nil
+		}
+
+		_, err = q.RenewSubscription(ctx, database.RenewSubscriptionParams{
+			ID:               sub.ID,
+			CurrentPeriodEnd: periodEnd,
+		})
+		return err
+	case "user.downgraded":
+		_, err := q.EndSubscription(ctx, database.EndSubscriptionParams{
+			UserID: userID,
+			Status: subscriptionCanceled,
+		})
+		return err
+	case "subscription.expired":
+		_, err := q.EndSubscription(ctx, database.EndSubscriptionParams{
+			UserID: userID,
+			Status: subscriptionExpired,
+		})
+		return err
+	default:
+		return nil
+	}
+}
+
+// entitlementsFor resolves the plan a user is on and returns its limits.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	isChirpyRed, err := cfg.db.IsChirpyRed(ctx, userID)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+
+	if isChirpyRed {
+		return cfg.plans.For(entitlements.PlanRed), nil
+	}
+	return cfg.plans.For(entitlements.PlanFree), nil
+}
+
+// expireSubscriptions periodically closes out memberships whose paid period
+// has ended without a renewal.
+func (cfg *apiConfig) expireSubscriptions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := cfg.db.ExpireLapsedSubscriptions(context.Background())
+		if err != nil {
+			log.Printf("Error expiring subscriptions: %s", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Expired %d lapsed subscriptions", n)
+		}
+	}
+}
+
+func (cfg *apiConfig) subscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	JWTTokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error getting bearer token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(JWTTokenString, cfg.keys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate token", err)
+		return
+	}
+
+	rows, err := cfg.db.ListSubscriptions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving subscriptions", err)
+		return
+	}
+
+	subscriptions := make([]Subscription, 0, len(rows))
+	for _, row := range rows {
+		sub := Subscription{
+			ID:                 row.ID,
+			Status:             row.Status,
+			CurrentPeriodStart: row.CurrentPeriodStart,
+			CurrentPeriodEnd:   row.CurrentPeriodEnd,
+		}
+		if row.EndedAt.Valid {
+			sub.EndedAt = &row.EndedAt.Time
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	respondWithJSON(w, http.StatusOK, subscriptions)
+}